@@ -0,0 +1,230 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RTMError represents an error reported by Slack over the RTM connection,
+// typically in reply to a message sent by the bot, e.g. because the message
+// was too long.
+type RTMError struct {
+	ReplyTo int32  // The ID of the message that failed, or zero if not a reply
+	Code    int    // The error code given by Slack
+	Msg     string // The description of the error given by Slack
+}
+
+func (err RTMError) Error() string {
+	if err.ReplyTo == 0 {
+		return fmt.Sprintf("RTM error %d: %s", err.Code, err.Msg)
+	}
+	return fmt.Sprintf("RTM error %d on message %d: %s", err.Code, err.ReplyTo, err.Msg)
+}
+
+// replyFrame represents the reply sent by Slack over the RTM connection in
+// response to a message sent by the bot. Unlike events, replies have no type.
+// Slack API doc: https://api.slack.com/rtm#sending_messages
+type replyFrame struct {
+	Ok      bool   `json:"ok"`
+	ReplyTo int32  `json:"reply_to"`
+	Ts      string `json:"ts"`
+	Text    string `json:"text"`
+	Error   struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+// ErrAckTimeout is returned by SendMessage when Slack does not acknowledge
+// a message within SendAckTimeout and FailOnAckTimeout is set.
+var ErrAckTimeout = errors.New("message not acknowledged")
+
+// pendingSend is a message sent, or waiting to be sent, over the RTM
+// connection that Slack has not yet replied to.
+type pendingSend struct {
+	message *messageOut
+	conn    conn       // The connection the message was written to, or nil if not yet written
+	waiting bool       // Is true while the sender waits for the reply on acked
+	acked   chan error // Receives the result of the reply if the sender waits for it
+}
+
+// addPending remembers a message sent with a given ID until it is replied to,
+// and returns a channel on which the result of the reply is delivered if
+// waiting is set.
+func (bot *SlackBot) addPending(id int32, message *messageOut, waiting bool) (acked <-chan error) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if bot.pending == nil {
+		bot.pending = make(map[int32]*pendingSend)
+	}
+	send := &pendingSend{message: message, waiting: waiting, acked: make(chan error, 1)}
+	bot.pending[id] = send
+	return send.acked
+}
+
+// takePending returns and forgets the message sent with a given ID.
+func (bot *SlackBot) takePending(id int32) (message *messageOut, ok bool) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	send, ok := bot.pending[id]
+	if !ok {
+		return
+	}
+	delete(bot.pending, id)
+	return send.message, true
+}
+
+// markWritten records that the message with a given ID was written to a
+// given connection.
+func (bot *SlackBot) markWritten(id int32, ws conn) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if send, ok := bot.pending[id]; ok {
+		send.conn = ws
+	}
+}
+
+// pendingMessage returns the message sent with a given ID, if it has not
+// been replied to.
+func (bot *SlackBot) pendingMessage(id int32) (message *messageOut, ok bool) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	send, ok := bot.pending[id]
+	if !ok {
+		return
+	}
+	return send.message, true
+}
+
+// completePending forgets the message sent with a given ID, which Slack
+// replied to with a given result, and delivers the result to the sender
+// if it waits for it. It reports whether the sender received the result.
+func (bot *SlackBot) completePending(id int32, result error) (message *messageOut, delivered bool) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	send, ok := bot.pending[id]
+	if !ok {
+		return
+	}
+	delete(bot.pending, id)
+	if send.waiting {
+		send.acked <- result
+	}
+	return send.message, send.waiting
+}
+
+// stopWaiting records that the sender of the message with a given ID no
+// longer waits for its reply on a given channel, so that the reply is
+// handled like those of other messages. If the reply was already delivered,
+// it is returned, with replied set.
+func (bot *SlackBot) stopWaiting(id int32, acked <-chan error) (result error, replied bool) {
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if send, ok := bot.pending[id]; ok {
+		send.waiting = false
+		return
+	}
+	select {
+	case result = <-acked:
+		return result, true
+	default:
+		return
+	}
+}
+
+// UnacknowledgedError is reported for a message sent over a connection that
+// was lost before Slack acknowledged the message, so that the message may or
+// may not have been delivered. See ResendUnacknowledged.
+type UnacknowledgedError struct {
+	Channel string
+	Text    string
+}
+
+func (err UnacknowledgedError) Error() string {
+	return fmt.Sprintf("message %s to channel %s may not have been delivered", err.Text, err.Channel)
+}
+
+// unacknowledged returns, ordered by ID, the messages written to a given
+// connection that Slack has not replied to. They are considered not written
+// from then on. It must be called once the connection is closed, and waits
+// for any message being written to it.
+func (bot *SlackBot) unacknowledged(lost conn) (messages []*messageOut) {
+	bot.writeMutex.Lock()
+	defer bot.writeMutex.Unlock()
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	for _, send := range bot.pending {
+		if lost != nil && send.conn == lost {
+			send.conn = nil
+			messages = append(messages, send.message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return
+}
+
+// handleUnacknowledged deals with given messages left unacknowledged when a
+// connection was lost, either resending them on the current connection or
+// reporting them as errors, also to senders waiting for their replies.
+func (bot *SlackBot) handleUnacknowledged(messages []*messageOut) {
+	for _, message := range messages {
+		if !bot.ResendUnacknowledged {
+			bot.failUnacknowledged(message)
+			continue
+		}
+		bot.logger.Printf("Resending message %s to channel %s\n", message.Text, message.Channel)
+		request := &sendRequest{ctx: context.Background(), channel: message.Channel, message: message, sent: make(chan error, 1)}
+		bot.enqueue(request)
+		go func() {
+			if err := <-request.sent; err != nil {
+				bot.reportError(err)
+			}
+		}()
+	}
+}
+
+// failUnacknowledged reports a given message left unacknowledged when a
+// connection was lost as an UnacknowledgedError, to its sender if it waits
+// for the reply.
+func (bot *SlackBot) failUnacknowledged(message *messageOut) {
+	err := UnacknowledgedError{Channel: message.Channel, Text: message.Text}
+	if _, delivered := bot.completePending(message.ID, err); !delivered {
+		bot.reportError(err)
+	}
+}
+
+// handleReply handles a reply to a message sent by the bot. Successful
+// replies complete the send, while errors are returned to the sender if it
+// waits for the reply per SendAckTimeout, and are otherwise reported like
+// callback errors.
+func (bot *SlackBot) handleReply(rawReply json.RawMessage) {
+	var reply replyFrame
+	if err := json.Unmarshal(rawReply, &reply); err != nil || reply.ReplyTo == 0 {
+		return
+	}
+	if bot.OnAck != nil {
+		bot.OnAck(reply.ReplyTo, reply.Ok, reply.Ts)
+	}
+	var result error
+	if !reply.Ok {
+		bot.counters.update(func(counters *counters) { counters.sendErrors++ })
+		result = RTMError{ReplyTo: reply.ReplyTo, Code: reply.Error.Code, Msg: reply.Error.Msg}
+		if isRateLimitError(result) {
+			bot.noteRateLimited()
+		}
+	}
+	if result == nil {
+		// Complete the send only afterwards, so that the sender returns
+		// after OnMessageSent is called
+		if message, ok := bot.pendingMessage(reply.ReplyTo); ok {
+			bot.messageSent(message.Channel, message.Text, reply.Ts)
+		}
+	}
+	// Senders waiting for the reply get any error instead
+	if _, delivered := bot.completePending(reply.ReplyTo, result); result != nil && !delivered {
+		bot.reportError(result)
+	}
+}
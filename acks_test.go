@@ -0,0 +1,306 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestCancelledSendIsForgotten(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendRate = 1
+	if err := bot.SendMessage("C1", "first"); err != nil {
+		t.Fatal(err)
+	}
+	first := c.next(t)
+	// The second message is held back by the rate limit until cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bot.SendMessageContext(ctx, "C1", "second"); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want deadline exceeded", err)
+	}
+	bot.Dispatch([]byte(`{"ok":true,"reply_to":1,"ts":"1.0"}`))
+	eventually(t, func() bool {
+		bot.pendingMutex.Lock()
+		defer bot.pendingMutex.Unlock()
+		return len(bot.pending) == 0
+	}, "cancelled message still pending")
+	if first["text"] != "first" {
+		t.Errorf("sent %v, want the first message", first)
+	}
+	select {
+	case message := <-c.sent:
+		t.Errorf("cancelled message was sent: %s", message)
+	case <-time.After(1100 * time.Millisecond):
+	}
+}
+
+func TestSendFromSyncCallbackDoesNotWaitForAck(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.DispatchSync = true
+	bot.SendAckTimeout = time.Second
+	bot.FailOnAckTimeout = true
+	results := make(chan error, 1)
+	bot.OnMessage = func(event MessageIn) error {
+		results <- bot.SendMessage(event.Channel, "reply")
+		return nil
+	}
+	go bot.listen()
+	c.push(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0"}`)
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Errorf("got error %v, want none", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("SendMessage waited for an ack that cannot be read")
+	}
+	if sent := c.next(t); sent["text"] != "reply" {
+		t.Errorf("sent %v, want the reply", sent)
+	}
+}
+
+func TestRTMErrorFrameReported(t *testing.T) {
+	bot, _ := newConnectedBot(t)
+	bot.CallbackErrors = make(chan error, 1)
+	bot.handleEvent([]byte(`{"ok":false,"reply_to":7,"error":{"code":2,"msg":"message text is missing"}}`))
+	select {
+	case err := <-bot.CallbackErrors:
+		rtmErr, ok := err.(RTMError)
+		if !ok || rtmErr.ReplyTo != 7 || rtmErr.Code != 2 || rtmErr.Msg != "message text is missing" {
+			t.Errorf("got error %#v", err)
+		}
+	default:
+		t.Fatal("RTM error frame not reported")
+	}
+}
+
+func TestRTMErrorFrameReturnedToSender(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendAckTimeout = time.Second
+	go bot.listen()
+	go func() {
+		var message messageOut
+		json.Unmarshal(<-c.sent, &message)
+		c.push(fmt.Sprintf(`{"ok":false,"reply_to":%d,"error":{"code":3,"msg":"channel not found"}}`, message.ID))
+	}()
+	err := bot.SendMessage("CNOPE", "hello")
+	if rtmErr, ok := err.(RTMError); !ok || rtmErr.Code != 3 || rtmErr.Msg != "channel not found" {
+		t.Errorf("got error %v, want the RTM error", err)
+	}
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if len(bot.pending) != 0 {
+		t.Error("failed message still pending")
+	}
+}
+
+func TestErrorEventReported(t *testing.T) {
+	bot, _ := newConnectedBot(t)
+	bot.CallbackErrors = make(chan error, 1)
+	bot.handleEvent([]byte(`{"type":"error","error":{"code":1,"msg":"Socket URL has expired"}}`))
+	select {
+	case err := <-bot.CallbackErrors:
+		if rtmErr, ok := err.(RTMError); !ok || rtmErr.Code != 1 || rtmErr.ReplyTo != 0 {
+			t.Errorf("got error %#v", err)
+		}
+	default:
+		t.Fatal("error event not reported")
+	}
+}
+
+func TestUnacknowledgedSendsResent(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.ResendUnacknowledged = true
+	if err := bot.SendMessage("C1", "maybe lost"); err != nil {
+		t.Fatal(err)
+	}
+	first := c.next(t)
+	// The connection drops before Slack acknowledges the message
+	c.Close()
+	unacknowledged := bot.unacknowledged(c)
+	reconnected := newFakeConn()
+	defer reconnected.Close()
+	bot.setConn(reconnected)
+	bot.handleUnacknowledged(unacknowledged)
+	resent := reconnected.next(t)
+	if resent["text"] != "maybe lost" || resent["client_msg_id"] != first["client_msg_id"] {
+		t.Errorf("resent %v, want %v again", resent, first)
+	}
+	bot.pendingMutex.Lock()
+	_, pending := bot.pending[int32(resent["id"].(float64))]
+	bot.pendingMutex.Unlock()
+	if !pending {
+		t.Error("resent message not awaiting acknowledgement")
+	}
+}
+
+func TestUnacknowledgedSendsReported(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.CallbackErrors = make(chan error, 1)
+	if err := bot.SendMessage("C1", "maybe lost"); err != nil {
+		t.Fatal(err)
+	}
+	c.next(t)
+	c.Close()
+	unacknowledged := bot.unacknowledged(c)
+	reconnected := newFakeConn()
+	defer reconnected.Close()
+	bot.setConn(reconnected)
+	bot.handleUnacknowledged(unacknowledged)
+	select {
+	case err := <-bot.CallbackErrors:
+		if unacked, ok := err.(UnacknowledgedError); !ok || unacked.Channel != "C1" || unacked.Text != "maybe lost" {
+			t.Errorf("got error %v", err)
+		}
+	default:
+		t.Fatal("unacknowledged message not reported")
+	}
+	select {
+	case message := <-reconnected.sent:
+		t.Errorf("message resent: %s", message)
+	default:
+	}
+}
+
+func TestUnacknowledgedOnlyWrittenSends(t *testing.T) {
+	for _, resend := range []bool{false, true} {
+		bot, api := newTestBot(t)
+		api.serveRTM()
+		bot.AutoReconnect = true
+		bot.ReconnectBaseDelay = time.Millisecond
+		bot.ResendUnacknowledged = resend
+		bot.SendRate = 5
+		bot.CallbackErrors = make(chan error, 10)
+		if err := bot.connect(); err != nil {
+			t.Fatal(err)
+		}
+		go bot.listen()
+		lost := api.socket(t)
+		if err := bot.SendMessage("C1", "written"); err != nil {
+			t.Fatal(err)
+		}
+		lost.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var message messageOut
+			if err := websocket.JSON.Receive(lost, &message); err != nil {
+				t.Fatalf("message not sent on the first connection: %v", err)
+			}
+			if message.Type == "message" {
+				break
+			}
+		}
+		// SendRate holds back this message while the connection drops
+		bot.SendAsync("C1", "queued")
+		lost.Close()
+		ws := api.socket(t)
+		texts := map[string]int{}
+		ws.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		for {
+			var message messageOut
+			if err := websocket.JSON.Receive(ws, &message); err != nil {
+				break
+			}
+			if message.Type == "message" {
+				texts[message.Text]++
+			}
+		}
+		bot.Disconnect()
+		want := map[string]int{"queued": 1}
+		if resend {
+			want["written"] = 1
+		}
+		if !reflect.DeepEqual(texts, want) {
+			t.Errorf("with resending %v, sent %v on the new connection, want %v", resend, texts, want)
+		}
+		var reported []error
+		for len(bot.CallbackErrors) > 0 {
+			reported = append(reported, <-bot.CallbackErrors)
+		}
+		if resend && len(reported) != 0 {
+			t.Errorf("reported %v while resending", reported)
+		}
+		if !resend {
+			if len(reported) != 1 {
+				t.Fatalf("reported %v, want the written message only", reported)
+			}
+			if unacked, ok := reported[0].(UnacknowledgedError); !ok || unacked.Text != "written" {
+				t.Errorf("reported %v, want the written message unacknowledged", reported[0])
+			}
+		}
+	}
+}
+
+func TestSendAckTimeout(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		bot, c := newConnectedBot(t)
+		bot.SendAckTimeout = 20 * time.Millisecond
+		bot.FailOnAckTimeout = fail
+		start := time.Now()
+		err := bot.SendMessage("C1", "unanswered")
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("gave up waiting for the ack after %v", elapsed)
+		}
+		if fail && err != ErrAckTimeout {
+			t.Errorf("got error %v, want ErrAckTimeout", err)
+		} else if !fail && err != nil {
+			t.Errorf("got error %v without FailOnAckTimeout", err)
+		}
+		c.next(t)
+	}
+}
+
+func TestSendAsyncDoesNotWaitForAck(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendAckTimeout = 50 * time.Millisecond
+	bot.FailOnAckTimeout = true
+	bot.CallbackErrors = make(chan error, 1)
+	start := time.Now()
+	bot.SendAsync("C1", "fire and forget")
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("SendAsync blocked for %v", elapsed)
+	}
+	if sent := c.next(t); sent["text"] != "fire and forget" {
+		t.Errorf("sent %v", sent)
+	}
+	// The missing ack is reported like a callback error instead
+	select {
+	case err := <-bot.CallbackErrors:
+		if err != ErrAckTimeout {
+			t.Errorf("got error %v, want ErrAckTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("missing ack not reported")
+	}
+}
+
+func TestOnAck(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.OnCallbackError = func(err error) {}
+	type ack struct {
+		replyTo int32
+		ok      bool
+		ts      string
+	}
+	var acks []ack
+	bot.OnAck = func(replyTo int32, ok bool, ts string) { acks = append(acks, ack{replyTo, ok, ts}) }
+	if err := bot.SendMessage("C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var sent messageOut
+	json.Unmarshal(<-c.sent, &sent)
+	bot.Dispatch([]byte(fmt.Sprintf(`{"ok":true,"reply_to":%d,"ts":"1.5","text":"hello"}`, sent.ID)))
+	// Replies to messages the bot no longer waits for are acks too
+	bot.Dispatch([]byte(`{"ok":false,"reply_to":99,"error":{"code":2,"msg":"message text is missing"}}`))
+	// Pongs are not
+	bot.Dispatch([]byte(`{"type":"pong","reply_to":3}`))
+	want := []ack{{sent.ID, true, "1.5"}, {99, false, ""}}
+	if len(acks) != len(want) || acks[0] != want[0] || acks[1] != want[1] {
+		t.Errorf("OnAck got %+v, want %+v", acks, want)
+	}
+}
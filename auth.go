@@ -0,0 +1,94 @@
+package slackbot
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthInfo describes the identity of the token used by the bot, as well as
+// the OAuth scopes granted to it.
+type AuthInfo struct {
+	URL          string   `json:"url"`
+	Team         string   `json:"team"`
+	User         string   `json:"user"`
+	TeamID       string   `json:"team_id"`
+	UserID       string   `json:"user_id"`
+	BotID        string   `json:"bot_id"`
+	EnterpriseID string   `json:"enterprise_id"`
+	Scopes       []string `json:"-"`
+}
+
+// authTestResponse represents a response sent by the Slack Web API method
+// auth.test. It is documented at https://api.slack.com/methods/auth.test
+type authTestResponse struct {
+	apiResponse
+	AuthInfo
+}
+
+// readHeader takes the scopes of the token from the response headers, which
+// is the only place Slack includes them.
+func (resp *authTestResponse) readHeader(header http.Header) {
+	for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			resp.Scopes = append(resp.Scopes, scope)
+		}
+	}
+}
+
+// AuthTest returns the identity of the bot's token through the Web API method
+// auth.test. As this is cheap, it may also be used to check connectivity.
+func (bot *SlackBot) AuthTest() (info AuthInfo, err error) {
+	var resp authTestResponse
+	if err = bot.callAPI("auth.test", url.Values{}, &resp); err != nil {
+		return
+	}
+	return resp.AuthInfo, nil
+}
+
+// Ping checks that Slack can be reached and that a given token is valid
+// through the Web API method auth.test, without connecting the bot or
+// changing its token. An invalid token gives an APIError with code
+// "invalid_auth".
+func (bot *SlackBot) Ping(token string) error {
+	req, err := http.NewRequest("POST", bot.methodURL("auth.test"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var resp authTestResponse
+	return bot.doAPIRequest("auth.test", req, &resp)
+}
+
+// MissingScopesError is returned by Start when the bot's token lacks some of
+// the scopes listed in RequiredScopes.
+type MissingScopesError struct {
+	Scopes []string // The required scopes not granted to the token
+}
+
+func (err MissingScopesError) Error() string {
+	return "token is missing required scopes: " + strings.Join(err.Scopes, ", ")
+}
+
+// checkScopes verifies that the bot's token has been granted all scopes
+// listed in RequiredScopes.
+func (bot *SlackBot) checkScopes() error {
+	info, err := bot.AuthTest()
+	if err != nil {
+		return err
+	}
+	granted := make(map[string]bool, len(info.Scopes))
+	for _, scope := range info.Scopes {
+		granted[scope] = true
+	}
+	var missing []string
+	for _, scope := range bot.RequiredScopes {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return MissingScopesError{Scopes: missing}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package slackbot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// authTestReply returns a successful auth.test response granting given scopes.
+func authTestReply(scopes string) apiStatus {
+	return apiStatus{
+		Code:   http.StatusOK,
+		Header: http.Header{"X-Oauth-Scopes": {scopes}},
+		Body: `{"ok":true,"url":"https://example.slack.com/","team":"Example","user":"bot",
+			"team_id":"T1","user_id":"UBOT","bot_id":"B1"}`,
+	}
+}
+
+func TestAuthTest(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("auth.test", authTestReply("chat:write, channels:history,reactions:write"))
+	info, err := bot.AuthTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL != "https://example.slack.com/" || info.Team != "Example" || info.User != "bot" ||
+		info.TeamID != "T1" || info.UserID != "UBOT" || info.BotID != "B1" {
+		t.Errorf("identity parsed as %+v", info)
+	}
+	if strings.Join(info.Scopes, " ") != "chat:write channels:history reactions:write" {
+		t.Errorf("scopes parsed as %q", info.Scopes)
+	}
+}
+
+func TestStartFailsOnMissingScopes(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	api.reply("auth.test", authTestReply("chat:write,channels:read"))
+	bot.RequiredScopes = []string{"chat:write", "reactions:write", "channels:read", "users:read"}
+	err := bot.Start("xoxb-test")
+	missing, ok := err.(MissingScopesError)
+	if !ok || strings.Join(missing.Scopes, ",") != "reactions:write,users:read" {
+		t.Fatalf("got error %v, want the missing scopes", err)
+	}
+	if !strings.Contains(err.Error(), "reactions:write, users:read") {
+		t.Errorf("error %q does not list the missing scopes", err)
+	}
+	if len(api.callsTo("rtm.connect")) != 0 {
+		t.Error("connected despite missing scopes")
+	}
+}
+
+func TestStartWithRequiredScopes(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	api.reply("auth.test", authTestReply("chat:write,channels:read"))
+	bot.RequiredScopes = []string{"chat:write"}
+	if err := bot.Start("xoxb-test"); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Disconnect()
+	api.socket(t)
+}
+
+func TestPing(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("auth.test", func(call apiCall) interface{} {
+		if call.Header.Get("Authorization") != "Bearer xoxb-other" {
+			return `{"ok":false,"error":"invalid_auth"}`
+		}
+		return authTestReply("")
+	})
+	if err := bot.Ping("xoxb-other"); err != nil {
+		t.Errorf("valid token gave error %v", err)
+	}
+	if apiErr, ok := bot.Ping("xoxb-wrong").(APIError); !ok || apiErr.Code != "invalid_auth" {
+		t.Error("invalid token did not give an invalid_auth error")
+	}
+	if bot.token != "xoxb-test" {
+		t.Error("Ping changed the token of the bot")
+	}
+	if bot.currentConn() != nil {
+		t.Error("Ping connected the bot")
+	}
+	api.server.Close()
+	if err := bot.Ping("xoxb-other"); err == nil {
+		t.Error("unreachable server gave no error")
+	}
+}
@@ -0,0 +1,73 @@
+package slackbot
+
+// backfillWindow is the number of recent messages in BackfillChannels that
+// are remembered to avoid handling a message both live and when backfilling.
+const backfillWindow = 1000
+
+// noteMessage records that a given message was received, and, if its
+// channel is one of BackfillChannels, reports whether it was received before.
+func (bot *SlackBot) noteMessage(event MessageIn) (seen bool) {
+	if event.Ts == "" || event.Channel == "" {
+		return false
+	}
+	if bot.isBackfillChannel(event.Channel) && bot.backfillSeen.isDuplicate(event.dedupKey(), backfillWindow) {
+		return true
+	}
+	bot.backfillMutex.Lock()
+	defer bot.backfillMutex.Unlock()
+	if bot.lastSeen == nil {
+		bot.lastSeen = make(map[string]string)
+	}
+	if last := bot.lastSeen[event.Channel]; last == "" || tsLess(last, event.Ts) {
+		bot.lastSeen[event.Channel] = event.Ts
+	}
+	return false
+}
+
+// LastTs returns the timestamp of the latest message received by the bot in
+// a given channel, across reconnections, or the empty string if none was
+// received. Messages are ordered by timestamp rather than arrival, so that
+// a message arriving late does not move LastTs back.
+func (bot *SlackBot) LastTs(channel string) string {
+	bot.backfillMutex.Lock()
+	defer bot.backfillMutex.Unlock()
+	return bot.lastSeen[channel]
+}
+
+// isBackfillChannel reports whether a given channel is one of BackfillChannels.
+func (bot *SlackBot) isBackfillChannel(channel string) bool {
+	for _, backfilled := range bot.BackfillChannels {
+		if backfilled == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// backfill fetches the messages posted in each of BackfillChannels after the
+// last message received in the channel, and handles them, oldest first, as
+// if they had been received. Channels in which no message was received yet
+// are skipped.
+func (bot *SlackBot) backfill() {
+	for _, channel := range bot.BackfillChannels {
+		bot.backfillMutex.Lock()
+		oldest := bot.lastSeen[channel]
+		bot.backfillMutex.Unlock()
+		if oldest == "" {
+			continue
+		}
+		messages, err := bot.history(channel, oldest, 0)
+		if err != nil {
+			bot.logger.Printf("Backfilling channel %s failed: %v\n", channel, err)
+			continue
+		}
+		bot.logger.Printf("Backfilling %d messages in channel %s\n", len(messages), channel)
+		for i := len(messages) - 1; i >= 0; i-- {
+			message := messages[i]
+			if !bot.eventAllowed(&message) || bot.hold(&message) {
+				continue
+			}
+			bot.dispatch(&message)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackfillAfterGap(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.BackfillChannels = []string{"C1"}
+	var received []string
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event.Channel+" "+event.Text)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"before","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C2","user":"U1","text":"not backfilled","ts":"1.0"}`))
+	// Slack returns the newest messages first
+	api.reply("conversations.history", `{"ok":true,"has_more":false,"messages":[
+		{"type":"message","user":"U2","text":"missed 2","ts":"3.0"},
+		{"type":"message","user":"U2","text":"missed 1","ts":"2.0"},
+		{"type":"message","user":"U1","text":"before","ts":"1.0"}]}`)
+	bot.backfill()
+	// A missed message may still arrive once the connection is back
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U2","text":"missed 2","ts":"3.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"after","ts":"4.0"}`))
+	want := []string{"C1 before", "C2 not backfilled", "C1 missed 1", "C1 missed 2", "C1 after"}
+	if strings.Join(received, ",") != strings.Join(want, ",") {
+		t.Errorf("OnMessage got %q, want %q", received, want)
+	}
+	calls := api.callsTo("conversations.history")
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls to conversations.history, want one for C1", len(calls))
+	}
+	if params := calls[0].Form; params.Get("channel") != "C1" || params.Get("oldest") != "1.0" {
+		t.Errorf("history fetched with parameters %v", params)
+	}
+	if last := bot.LastTs("C1"); last != "4.0" {
+		t.Errorf("LastTs is %q, want 4.0", last)
+	}
+}
+
+func TestLastTsTracksMaximum(t *testing.T) {
+	bot, _ := newTestBot(t)
+	if last := bot.LastTs("C1"); last != "" {
+		t.Errorf("LastTs is %q before any message", last)
+	}
+	for _, message := range []string{
+		`{"type":"message","channel":"C1","user":"U1","text":"a","ts":"9.000100"}`,
+		`{"type":"message","channel":"C1","user":"U1","text":"b","ts":"10.000001"}`,
+		// Arriving late does not move LastTs back
+		`{"type":"message","channel":"C1","user":"U1","text":"c","ts":"9.000200"}`,
+		`{"type":"message","channel":"C2","user":"U1","text":"d","ts":"5.000000"}`,
+	} {
+		bot.Dispatch([]byte(message))
+	}
+	if last := bot.LastTs("C1"); last != "10.000001" {
+		t.Errorf("LastTs of C1 is %q, want 10.000001", last)
+	}
+	if last := bot.LastTs("C2"); last != "5.000000" {
+		t.Errorf("LastTs of C2 is %q, want 5.000000", last)
+	}
+}
@@ -0,0 +1,69 @@
+package slackbot
+
+import "encoding/json"
+
+// Block represents a layout block of a message. Only rich_text blocks,
+// which Slack adds to messages posted by users, are parsed in full; they
+// describe the text of the message with its formatting, mentions, and links.
+// Slack API doc: https://api.slack.com/reference/block-kit/blocks#rich_text
+type Block struct {
+	Type     string            `json:"type"` // E.g. "rich_text" or "section"
+	BlockID  string            `json:"block_id"`
+	Elements []RichTextElement `json:"elements"`
+}
+
+// UnmarshalJSON parses a block, leaving Elements empty unless it is a
+// rich_text block, as the elements of other blocks take other shapes.
+func (block *Block) UnmarshalJSON(data []byte) (err error) {
+	var header struct {
+		Type     string          `json:"type"`
+		BlockID  string          `json:"block_id"`
+		Elements json.RawMessage `json:"elements"`
+	}
+	if err = json.Unmarshal(data, &header); err != nil {
+		return
+	}
+	*block = Block{Type: header.Type, BlockID: header.BlockID}
+	if header.Type == "rich_text" && header.Elements != nil {
+		err = json.Unmarshal(header.Elements, &block.Elements)
+	}
+	return
+}
+
+// RichTextElement represents an element of a rich_text block. Containers,
+// such as "rich_text_section", "rich_text_list", "rich_text_quote", and
+// "rich_text_preformatted", hold further Elements, while the remaining
+// elements, such as "text", "user", "channel", and "link", hold content.
+type RichTextElement struct {
+	Type        string            `json:"type"`
+	Elements    []RichTextElement `json:"elements"`
+	Text        string            `json:"text"`         // For text and link elements
+	UserID      string            `json:"user_id"`      // For user elements, i.e. mentions
+	ChannelID   string            `json:"channel_id"`   // For channel elements
+	UsergroupID string            `json:"usergroup_id"` // For usergroup elements
+	URL         string            `json:"url"`          // For link elements
+	Name        string            `json:"name"`         // For emoji elements
+	Range       string            `json:"range"`        // For broadcast elements, e.g. "here" or "channel"
+}
+
+// MentionedUsers returns the IDs of the users mentioned in the rich_text
+// blocks of the message, in order of appearance and without duplicates.
+func (event MessageIn) MentionedUsers() (users []string) {
+	seen := make(map[string]bool)
+	var walk func(elements []RichTextElement)
+	walk = func(elements []RichTextElement) {
+		for _, element := range elements {
+			if element.Type == "user" && !seen[element.UserID] {
+				seen[element.UserID] = true
+				users = append(users, element.UserID)
+			}
+			walk(element.Elements)
+		}
+	}
+	for _, block := range event.Blocks {
+		if block.Type == "rich_text" {
+			walk(block.Elements)
+		}
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRichTextMentions(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received MessageIn
+	bot.OnMessage = func(event MessageIn) error {
+		received = event
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U9","ts":"1.0",
+		"text":"<@U1> and <@U2>: see <https://example.com|the docs>\n• <@U1> again",
+		"blocks":[
+			{"type":"section","block_id":"s1","text":{"type":"mrkdwn","text":"<@U3>"}},
+			{"type":"rich_text","block_id":"r1","elements":[
+				{"type":"rich_text_section","elements":[
+					{"type":"user","user_id":"U1"},
+					{"type":"text","text":" and "},
+					{"type":"user","user_id":"U2"},
+					{"type":"text","text":": see "},
+					{"type":"link","url":"https://example.com","text":"the docs"}
+				]},
+				{"type":"rich_text_list","style":"bullet","elements":[
+					{"type":"rich_text_section","elements":[
+						{"type":"user","user_id":"U1"},
+						{"type":"text","text":" again"}
+					]}
+				]}
+			]}
+		]}`))
+	if mentioned := strings.Join(received.MentionedUsers(), ","); mentioned != "U1,U2" {
+		t.Errorf("mentioned users %s, want U1,U2", mentioned)
+	}
+	if len(received.Blocks) != 2 || len(received.Blocks[0].Elements) != 0 {
+		t.Fatalf("blocks parsed as %+v", received.Blocks)
+	}
+	section := received.Blocks[1].Elements[0]
+	if link := section.Elements[4]; link.Type != "link" || link.URL != "https://example.com" || link.Text != "the docs" {
+		t.Errorf("link parsed as %+v", link)
+	}
+}
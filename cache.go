@@ -0,0 +1,190 @@
+package slackbot
+
+import (
+	"net/url"
+	"strings"
+)
+
+// User represents a member of a Slack team.
+// Slack API doc: https://api.slack.com/types/user
+type User struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	RealName string      `json:"real_name"`
+	Deleted  bool        `json:"deleted"`
+	IsBot    bool        `json:"is_bot"`
+	TZ       string      `json:"tz"`        // The user's time zone, e.g. "Europe/Copenhagen"
+	TZLabel  string      `json:"tz_label"`  // A description of the time zone, e.g. "Central European Summer Time"
+	TZOffset int         `json:"tz_offset"` // The offset of the time zone from UTC, in seconds
+	Profile  UserProfile `json:"profile"`
+}
+
+// UserProfile holds the profile information of a Slack user, including their
+// custom status.
+// Slack API doc: https://api.slack.com/types/user
+type UserProfile struct {
+	DisplayName      string        `json:"display_name"`
+	RealName         string        `json:"real_name"`
+	Email            string        `json:"email"`
+	Title            string        `json:"title"`
+	Phone            string        `json:"phone"`
+	StatusText       string        `json:"status_text"`
+	StatusEmoji      string        `json:"status_emoji"`
+	StatusExpiration UnixTimestamp `json:"status_expiration"` // Zero if the status does not expire
+	Image48          string        `json:"image_48"`
+}
+
+// Channel represents a Slack conversation: a public channel, a private
+// channel (group), or a direct message (IM).
+// Slack API doc: https://api.slack.com/types/conversation
+type Channel struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IsChannel  bool   `json:"is_channel"`
+	IsGroup    bool   `json:"is_group"`
+	IsIM       bool   `json:"is_im"`
+	IsMPIM     bool   `json:"is_mpim"`
+	IsPrivate  bool   `json:"is_private"`
+	IsArchived bool   `json:"is_archived"`
+	IsMember   bool   `json:"is_member"`
+	User       string `json:"user"` // The other party, for direct messages
+}
+
+// Channel types, as returned by ChannelType.
+const (
+	ChannelTypePublic  = "public"  // A public channel
+	ChannelTypePrivate = "private" // A private channel
+	ChannelTypeIM      = "im"      // A direct message between two users
+	ChannelTypeMPIM    = "mpim"    // A multi-person direct message
+)
+
+// ChannelType returns the type of the channel with a given ID based on its
+// prefix, or the empty string if the prefix is unknown. Multi-person direct
+// messages share their prefix with private channels, so from the ID alone
+// they cannot be told apart, and both are reported as private; private
+// channels created in recent years may also use the prefix of public
+// channels. The method of the same name on SlackBot also takes cached
+// channel information into account.
+func ChannelType(id string) string {
+	switch {
+	case strings.HasPrefix(id, "C"):
+		return ChannelTypePublic
+	case strings.HasPrefix(id, "G"):
+		return ChannelTypePrivate
+	case strings.HasPrefix(id, "D"):
+		return ChannelTypeIM
+	}
+	return ""
+}
+
+// ChannelType returns the type of the channel with a given ID. If the
+// channel is cached, its flags decide the type, so that multi-person direct
+// messages are reported as such. Otherwise, the type is found from the
+// prefix of the ID, as by the package-level ChannelType, and a multi-person
+// direct message cannot be told apart from a private channel.
+func (bot *SlackBot) ChannelType(id string) string {
+	channel, ok := bot.CachedChannel(id)
+	switch {
+	case !ok:
+		return ChannelType(id)
+	case channel.IsMPIM:
+		return ChannelTypeMPIM
+	case channel.IsIM:
+		return ChannelTypeIM
+	case channel.IsPrivate || channel.IsGroup:
+		return ChannelTypePrivate
+	}
+	return ChannelTypePublic
+}
+
+// CachedUser returns the user with a given ID if it is known to the bot.
+func (bot *SlackBot) CachedUser(id string) (user User, ok bool) {
+	bot.cacheMutex.RLock()
+	defer bot.cacheMutex.RUnlock()
+	user, ok = bot.users[id]
+	return
+}
+
+// CachedChannel returns the channel with a given ID if it is known to the bot.
+func (bot *SlackBot) CachedChannel(id string) (channel Channel, ok bool) {
+	bot.cacheMutex.RLock()
+	defer bot.cacheMutex.RUnlock()
+	channel, ok = bot.channels[id]
+	return
+}
+
+// usersInfoResponse represents a response sent by the Slack Web API method
+// users.info. It is documented at https://api.slack.com/methods/users.info
+type usersInfoResponse struct {
+	apiResponse
+	User User `json:"user"`
+}
+
+// UserInfo returns the user with a given ID, from the cache if it is known
+// to the bot, and otherwise from the Web API, after which it is cached.
+func (bot *SlackBot) UserInfo(id string) (user User, err error) {
+	if user, ok := bot.CachedUser(id); ok {
+		return user, nil
+	}
+	var resp usersInfoResponse
+	if err = bot.callAPI("users.info", url.Values{"user": {id}}, &resp); err != nil {
+		return
+	}
+	bot.cacheUser(resp.User)
+	return resp.User, nil
+}
+
+// conversationsInfoResponse represents a response sent by the Slack Web API method
+// conversations.info. It is documented at https://api.slack.com/methods/conversations.info
+type conversationsInfoResponse struct {
+	apiResponse
+	Channel Channel `json:"channel"`
+}
+
+// ChannelInfo returns the channel with a given ID, from the cache if it is
+// known to the bot, and otherwise from the Web API, after which it is cached.
+func (bot *SlackBot) ChannelInfo(id string) (channel Channel, err error) {
+	if channel, ok := bot.CachedChannel(id); ok {
+		return channel, nil
+	}
+	var resp conversationsInfoResponse
+	if err = bot.callAPI("conversations.info", url.Values{"channel": {id}}, &resp); err != nil {
+		return
+	}
+	bot.cacheChannel(resp.Channel)
+	return resp.Channel, nil
+}
+
+// cacheChannel adds or updates a channel in the cache.
+func (bot *SlackBot) cacheChannel(channel Channel) {
+	bot.cacheMutex.Lock()
+	defer bot.cacheMutex.Unlock()
+	if bot.channels == nil {
+		bot.channels = make(map[string]Channel)
+	}
+	bot.channels[channel.ID] = channel
+}
+
+// cacheUser adds or updates a user in the cache.
+func (bot *SlackBot) cacheUser(user User) {
+	bot.cacheMutex.Lock()
+	defer bot.cacheMutex.Unlock()
+	if bot.users == nil {
+		bot.users = make(map[string]User)
+	}
+	bot.users[user.ID] = user
+}
+
+// fillCaches replaces the contents of the user and channel caches.
+func (bot *SlackBot) fillCaches(users []User, channels []Channel) {
+	bot.cacheMutex.Lock()
+	defer bot.cacheMutex.Unlock()
+	bot.users = make(map[string]User, len(users))
+	for _, user := range users {
+		bot.users[user.ID] = user
+	}
+	bot.channels = make(map[string]Channel, len(channels))
+	for _, channel := range channels {
+		bot.channels[channel.ID] = channel
+	}
+}
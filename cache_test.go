@@ -0,0 +1,155 @@
+package slackbot
+
+import "testing"
+
+func TestRTMStartFillsCaches(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.UseRTMStart = true
+	api.reply("rtm.start", map[string]interface{}{
+		"ok":       true,
+		"url":      api.socketURL(),
+		"self":     map[string]string{"id": "UBOT", "name": "bot"},
+		"users":    []map[string]string{{"id": "U1", "name": "alice"}, {"id": "U2", "name": "bob"}},
+		"channels": []map[string]string{{"id": "C1", "name": "general"}},
+		"groups":   []map[string]string{{"id": "G1", "name": "secret"}},
+		"ims":      []map[string]string{{"id": "D1", "user": "U1"}},
+	})
+	if err := bot.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.currentConn().Close()
+	api.socket(t)
+	if len(api.callsTo("rtm.start")) != 1 || len(api.callsTo("rtm.connect")) != 0 {
+		t.Error("rtm.start not used in place of rtm.connect")
+	}
+	if user, ok := bot.CachedUser("U2"); !ok || user.Name != "bob" {
+		t.Errorf("got user %+v, %v from cache", user, ok)
+	}
+	for _, id := range []string{"C1", "G1", "D1"} {
+		if _, ok := bot.CachedChannel(id); !ok {
+			t.Errorf("channel %s not cached", id)
+		}
+	}
+	if _, err := bot.UserInfo("U1"); err != nil || len(api.callsTo("users.info")) != 0 {
+		t.Errorf("UserInfo did not use the cache: %v", err)
+	}
+	if bot.selfID() != "UBOT" {
+		t.Errorf("bot identified as %q", bot.selfID())
+	}
+}
+
+func TestRTMConnectLeavesCachesEmpty(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	if err := bot.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.currentConn().Close()
+	if _, ok := bot.CachedUser("U1"); ok {
+		t.Error("cache filled without rtm.start")
+	}
+}
+
+func TestChannelType(t *testing.T) {
+	for id, want := range map[string]string{
+		"C123": ChannelTypePublic,
+		"G123": ChannelTypePrivate,
+		"D123": ChannelTypeIM,
+		"U123": "",
+		"":     "",
+	} {
+		if got := ChannelType(id); got != want {
+			t.Errorf("ChannelType(%q) = %q, want %q", id, got, want)
+		}
+	}
+	if !(MessageIn{Channel: "D123"}).IsDirectMessage() || (MessageIn{Channel: "C123"}).IsDirectMessage() {
+		t.Error("IsDirectMessage does not follow the channel type")
+	}
+}
+
+func TestChannelTypeFromCache(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.fillCaches(nil, []Channel{
+		{ID: "G1", IsMPIM: true, IsPrivate: true},
+		{ID: "G2", IsGroup: true, IsPrivate: true},
+		{ID: "C3", IsChannel: true, IsPrivate: true},
+		{ID: "C4", IsChannel: true},
+	})
+	for id, want := range map[string]string{
+		"G1": ChannelTypeMPIM,
+		"G2": ChannelTypePrivate,
+		"C3": ChannelTypePrivate,
+		"C4": ChannelTypePublic,
+		// Without cached information, a multi-person direct message is
+		// indistinguishable from a private channel
+		"G5": ChannelTypePrivate,
+		"D6": ChannelTypeIM,
+	} {
+		if got := bot.ChannelType(id); got != want {
+			t.Errorf("ChannelType(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+// realisticUser is a user object as sent by Slack, with a custom status.
+const realisticUser = `{"id":"U1","team_id":"T1","name":"alice","deleted":false,"real_name":"Alice Smith",
+	"tz":"Europe/Copenhagen","tz_label":"Central European Summer Time","tz_offset":7200,
+	"profile":{"title":"Engineer","phone":"+45 1234 5678","skype":"","real_name":"Alice Smith",
+		"display_name":"ali","status_text":"On holiday","status_emoji":":palm_tree:",
+		"status_expiration":1700000000,"email":"alice@example.com","image_48":"https://example.com/a.png"},
+	"is_admin":false,"is_bot":false,"updated":1690000000}`
+
+// checkRealisticUser checks that a user was unmarshalled from realisticUser.
+func checkRealisticUser(t *testing.T, user User) {
+	t.Helper()
+	profile := user.Profile
+	if user.ID != "U1" || user.Name != "alice" || user.TZ != "Europe/Copenhagen" || user.TZOffset != 7200 {
+		t.Errorf("user parsed as %+v", user)
+	}
+	if profile.Email != "alice@example.com" || profile.Title != "Engineer" || profile.Phone != "+45 1234 5678" ||
+		profile.DisplayName != "ali" || profile.RealName != "Alice Smith" {
+		t.Errorf("profile parsed as %+v", profile)
+	}
+	if profile.StatusText != "On holiday" || profile.StatusEmoji != ":palm_tree:" || profile.StatusExpiration != 1700000000 {
+		t.Errorf("status parsed as %+v", profile)
+	}
+}
+
+func TestUserProfileInEvents(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var users []User
+	bot.OnTeamJoin = func(event TeamJoin) error {
+		users = append(users, event.User)
+		return nil
+	}
+	bot.OnUserChange = func(event UserChange) error {
+		users = append(users, event.User)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"team_join","user":` + realisticUser + `}`))
+	bot.handleEvent([]byte(`{"type":"user_change","user":` + realisticUser + `}`))
+	if len(users) != 2 {
+		t.Fatalf("got %d users, want 2", len(users))
+	}
+	for _, user := range users {
+		checkRealisticUser(t, user)
+	}
+}
+
+func TestUserInfoCachesProfile(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("users.info", `{"ok":true,"user":`+realisticUser+`}`)
+	user, err := bot.UserInfo("U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRealisticUser(t, user)
+	cached, ok := bot.CachedUser("U1")
+	if !ok {
+		t.Fatal("user not cached")
+	}
+	checkRealisticUser(t, cached)
+	if _, err := bot.UserInfo("U1"); err != nil || len(api.callsTo("users.info")) != 1 {
+		t.Error("user fetched despite being cached")
+	}
+}
@@ -0,0 +1,43 @@
+package slackbot
+
+import "strings"
+
+// eventAllowed reports whether a given event should be passed on according
+// to AllowedChannels and DeniedChannels.
+func (bot *SlackBot) eventAllowed(event event) bool {
+	switch event := event.(type) {
+	case *MessageIn:
+		return bot.channelAllowed(event.Channel)
+	case *AppMention:
+		return bot.channelAllowed(event.Channel)
+	case *interaction:
+		return bot.channelAllowed(event.callback.Channel.ID)
+	case *slashCommand:
+		return bot.channelAllowed(event.payload.ChannelID)
+	}
+	return true
+}
+
+// channelAllowed reports whether events in the channel with a given ID should
+// be passed on according to AllowedChannels and DeniedChannels.
+func (bot *SlackBot) channelAllowed(channel string) bool {
+	if channel == "" || (len(bot.AllowedChannels) == 0 && len(bot.DeniedChannels) == 0) {
+		return true
+	}
+	if bot.channelListed(channel, bot.DeniedChannels) {
+		return false
+	}
+	return len(bot.AllowedChannels) == 0 || bot.channelListed(channel, bot.AllowedChannels)
+}
+
+// channelListed reports whether the channel with a given ID is in a given
+// list of channel IDs and names. Names may be given with or without "#".
+func (bot *SlackBot) channelListed(channel string, list []string) bool {
+	cached, known := bot.CachedChannel(channel)
+	for _, listed := range list {
+		if listed == channel || (known && cached.Name != "" && strings.TrimPrefix(listed, "#") == cached.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package slackbot
+
+import (
+	"fmt"
+	"testing"
+)
+
+// dispatchToChannels dispatches a message to each of a given list of channels
+// and returns the channels of the messages passed to OnMessage.
+func dispatchToChannels(bot *SlackBot, channels ...string) (received []string) {
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event.Channel)
+		return nil
+	}
+	for _, channel := range channels {
+		bot.Dispatch([]byte(fmt.Sprintf(`{"type":"message","channel":%q,"user":"U1","text":"hello","ts":"1.0"}`, channel)))
+	}
+	return
+}
+
+func TestAllowedChannels(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.fillCaches(nil, []Channel{{ID: "C2", Name: "deploys"}})
+	bot.AllowedChannels = []string{"C1", "#deploys"}
+	received := dispatchToChannels(bot, "C1", "C2", "C3")
+	if len(received) != 2 || received[0] != "C1" || received[1] != "C2" {
+		t.Errorf("passed on messages in %v, want C1 and C2", received)
+	}
+}
+
+func TestDeniedChannels(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.fillCaches(nil, []Channel{{ID: "C2", Name: "random"}})
+	bot.DeniedChannels = []string{"C1", "random"}
+	received := dispatchToChannels(bot, "C1", "C2", "C3")
+	if len(received) != 1 || received[0] != "C3" {
+		t.Errorf("passed on messages in %v, want C3", received)
+	}
+	// Denying takes precedence over allowing
+	bot.AllowedChannels = []string{"C1", "C3"}
+	received = dispatchToChannels(bot, "C1", "C3")
+	if len(received) != 1 || received[0] != "C3" {
+		t.Errorf("passed on messages in %v, want C3", received)
+	}
+}
+
+func TestChannelFilterEventTypes(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.AllowedChannels = []string{"C1"}
+	var mentions []AppMention
+	bot.OnAppMention = func(event AppMention) error {
+		mentions = append(mentions, event)
+		return nil
+	}
+	var presences int
+	bot.OnPresenceChange = func(event PresenceChange) error {
+		presences++
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"app_mention","channel":"C2","user":"U1","text":"<@UBOT> hi","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"app_mention","channel":"C1","user":"U1","text":"<@UBOT> hi","ts":"2.0"}`))
+	// Events outside of channels are not filtered
+	bot.Dispatch([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	if len(mentions) != 1 || mentions[0].Channel != "C1" {
+		t.Errorf("passed on mentions %+v", mentions)
+	}
+	if presences != 1 {
+		t.Errorf("OnPresenceChange called %d times, want 1", presences)
+	}
+}
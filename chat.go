@@ -0,0 +1,182 @@
+package slackbot
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PostMessageParameters describes a message to be sent through the Web API
+// method chat.postMessage. Unlike SendMessage, which sends plain text over
+// the RTM connection, this supports Slack's richer message options.
+// Slack API doc: https://api.slack.com/methods/chat.postMessage
+type PostMessageParameters struct {
+	Channel        string `json:"channel"`
+	Text           string `json:"text"`
+	ThreadTs       string `json:"thread_ts,omitempty"`       // The timestamp of the parent message, for replies in a thread
+	ReplyBroadcast bool   `json:"reply_broadcast,omitempty"` // Whether a reply in a thread is also shown in the channel
+	UnfurlLinks    *bool  `json:"unfurl_links,omitempty"`    // Whether to unfurl links to text content; Slack decides if nil
+	UnfurlMedia    *bool  `json:"unfurl_media,omitempty"`    // Whether to unfurl links to media content; Slack decides if nil
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment represents a legacy message attachment, shown below the text
+// of a message with a colored bar along its side.
+// Slack API doc: https://api.slack.com/reference/messaging/attachments
+type Attachment struct {
+	Fallback  string            `json:"fallback,omitempty"` // Plain text summary shown where the attachment cannot be
+	Color     string            `json:"color,omitempty"`    // "good", "warning", "danger", or a hex color such as "#439FE0"
+	Pretext   string            `json:"pretext,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	TitleLink string            `json:"title_link,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Fields    []AttachmentField `json:"fields,omitempty"`
+	Footer    string            `json:"footer,omitempty"`
+	Ts        int64             `json:"ts,omitempty"` // Unix time shown in the footer
+}
+
+// AttachmentField represents a field shown in a table in an attachment.
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"` // Whether the field is short enough to be shown next to another
+}
+
+// postMessageResponse represents a response sent by the Slack Web API methods
+// chat.postMessage and chat.meMessage. They are documented at
+// https://api.slack.com/methods/chat.postMessage and https://api.slack.com/methods/chat.meMessage
+type postMessageResponse struct {
+	apiResponse
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+// PostMessage sends a message through the Web API and returns the
+// timestamp identifying the posted message. Like SendMessage, it respects
+// SendRate, and messages to the same channel are sent in order.
+func (bot *SlackBot) PostMessage(params PostMessageParameters) (ts string, err error) {
+	if bot.ValidateFormatting {
+		if err = ValidateFormatting(params.Text); err != nil {
+			return
+		}
+	}
+	if bot.DryRun {
+		bot.recordDryRun(params.Channel, params.Text)
+		return
+	}
+	bot.logger.Printf("Posting message %s to channel %s\n", params.Text, params.Channel)
+	var resp postMessageResponse
+	err = bot.throttle(params.Channel, func() error {
+		return bot.callAPIJSON("chat.postMessage", params, &resp)
+	})
+	if err != nil {
+		bot.postFailed(err)
+		return
+	}
+	bot.messageSent(params.Channel, params.Text, resp.Ts)
+	return resp.Ts, nil
+}
+
+// postFailed records that a message could not be sent through the Web API
+// because of a given error.
+func (bot *SlackBot) postFailed(err error) {
+	bot.counters.update(func(counters *counters) { counters.sendErrors++ })
+	if isRateLimitError(err) {
+		bot.noteSendRateLimited()
+	}
+}
+
+// Reply replies to a given message in its thread, starting a new thread if
+// the message is not already part of one. If broadcast is set, the reply is
+// also shown in the channel.
+func (bot *SlackBot) Reply(message MessageIn, text string, broadcast bool) error {
+	threadTs := message.ThreadTs
+	if threadTs == "" {
+		threadTs = message.Ts
+	}
+	_, err := bot.PostMessage(PostMessageParameters{
+		Channel:        message.Channel,
+		Text:           text,
+		ThreadTs:       threadTs,
+		ReplyBroadcast: broadcast,
+	})
+	return err
+}
+
+// scheduleMessageResponse represents a response sent by the Slack Web API method
+// chat.scheduleMessage. It is documented at https://api.slack.com/methods/chat.scheduleMessage
+type scheduleMessageResponse struct {
+	apiResponse
+	Channel            string `json:"channel"`
+	ScheduledMessageID string `json:"scheduled_message_id"`
+	PostAt             int64  `json:"post_at"`
+}
+
+// ScheduleMessage schedules a message to be sent to a given channel at a given
+// time, and returns the ID of the scheduled message, which can be used
+// to cancel it through DeleteScheduledMessage.
+func (bot *SlackBot) ScheduleMessage(channel, text string, postAt time.Time) (scheduledMessageID string, err error) {
+	if bot.DryRun {
+		bot.recordDryRunScheduled(channel, text, postAt)
+		return
+	}
+	bot.logger.Printf("Scheduling message %s to channel %s at %v\n", text, channel, postAt)
+	params := url.Values{
+		"channel": {channel},
+		"text":    {text},
+		"post_at": {strconv.FormatInt(postAt.Unix(), 10)},
+	}
+	var resp scheduleMessageResponse
+	if err = bot.callAPI("chat.scheduleMessage", params, &resp); err != nil {
+		return
+	}
+	return resp.ScheduledMessageID, nil
+}
+
+// DeleteScheduledMessage cancels a message previously scheduled through
+// ScheduleMessage, before it is sent. In dry-run mode, it only logs the
+// message it would have cancelled.
+// Slack API doc: https://api.slack.com/methods/chat.deleteScheduledMessage
+func (bot *SlackBot) DeleteScheduledMessage(channel, scheduledMessageID string) error {
+	if bot.DryRun {
+		bot.logger.Printf("Dry run: not deleting scheduled message %s in channel %s\n", scheduledMessageID, channel)
+		return nil
+	}
+	params := url.Values{
+		"channel":              {channel},
+		"scheduled_message_id": {scheduledMessageID},
+	}
+	var resp apiResponse
+	return bot.callAPI("chat.deleteScheduledMessage", params, &resp)
+}
+
+// SendMeMessage sends a message to a given channel describing an action of
+// the bot, as if posted through "/me", e.g. "waves".
+// Slack API doc: https://api.slack.com/methods/chat.meMessage
+func (bot *SlackBot) SendMeMessage(channel, text string) error {
+	if bot.ValidateFormatting {
+		if err := ValidateFormatting(text); err != nil {
+			return err
+		}
+	}
+	if bot.DryRun {
+		bot.recordDryRun(channel, text)
+		return nil
+	}
+	bot.logger.Printf("Sending me message %s to channel %s\n", text, channel)
+	params := url.Values{
+		"channel": {channel},
+		"text":    {text},
+	}
+	var resp postMessageResponse
+	err := bot.throttle(channel, func() error {
+		return bot.callAPI("chat.meMessage", params, &resp)
+	})
+	if err != nil {
+		bot.postFailed(err)
+		return err
+	}
+	bot.messageSent(channel, text, resp.Ts)
+	return nil
+}
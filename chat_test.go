@@ -0,0 +1,154 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPostMessageUnfurlFlags(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.postMessage", `{"ok":true,"channel":"C1","ts":"1.5"}`)
+	no, yes := false, true
+	if _, err := bot.PostMessage(PostMessageParameters{Channel: "C1", Text: "https://example.com", UnfurlLinks: &no, UnfurlMedia: &yes}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.PostMessage(PostMessageParameters{Channel: "C1", Text: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("chat.postMessage")
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls to chat.postMessage, want 2", len(calls))
+	}
+	var set, unset map[string]interface{}
+	json.Unmarshal(calls[0].Body, &set)
+	json.Unmarshal(calls[1].Body, &unset)
+	if set["unfurl_links"] != false || set["unfurl_media"] != true {
+		t.Errorf("flags serialized as %s", calls[0].Body)
+	}
+	if _, ok := unset["unfurl_links"]; ok {
+		t.Errorf("unset flags serialized as %s", calls[1].Body)
+	}
+	if _, ok := unset["unfurl_media"]; ok {
+		t.Errorf("unset flags serialized as %s", calls[1].Body)
+	}
+}
+
+func TestScheduleMessage(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.scheduleMessage", `{"ok":true,"channel":"C1","scheduled_message_id":"Q1","post_at":1700000000}`)
+	id, err := bot.ScheduleMessage("C1", "reminder", time.Unix(1700000000, 500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "Q1" {
+		t.Errorf("got scheduled message ID %q, want Q1", id)
+	}
+	form := api.callsTo("chat.scheduleMessage")[0].Form
+	if form.Get("post_at") != "1700000000" || form.Get("channel") != "C1" || form.Get("text") != "reminder" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+}
+
+func TestDeleteScheduledMessage(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.deleteScheduledMessage", `{"ok":false,"error":"invalid_scheduled_message_id"}`)
+	err := bot.DeleteScheduledMessage("C1", "Q1")
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code != "invalid_scheduled_message_id" {
+		t.Errorf("got error %v", err)
+	}
+	if form := api.callsTo("chat.deleteScheduledMessage")[0].Form; form.Get("scheduled_message_id") != "Q1" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+}
+
+func TestReplyThreadTs(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.postMessage", `{"ok":true,"channel":"C1","ts":"9.0"}`)
+	topLevel := MessageIn{Channel: "C1", Ts: "1.0"}
+	inThread := MessageIn{Channel: "C1", Ts: "3.0", ThreadTs: "2.0"}
+	if err := bot.Reply(topLevel, "starting a thread", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.Reply(inThread, "continuing it", true); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("chat.postMessage")
+	var first, second PostMessageParameters
+	json.Unmarshal(calls[0].Body, &first)
+	json.Unmarshal(calls[1].Body, &second)
+	if first.ThreadTs != "1.0" || first.ReplyBroadcast || first.Channel != "C1" {
+		t.Errorf("reply to a top-level message posted as %s", calls[0].Body)
+	}
+	if second.ThreadTs != "2.0" || !second.ReplyBroadcast {
+		t.Errorf("reply in a thread posted as %s", calls[1].Body)
+	}
+}
+
+func TestPostMessageAttachments(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.postMessage", `{"ok":true,"channel":"C1","ts":"1.5"}`)
+	attachment := Attachment{
+		Fallback: "Deploy finished",
+		Color:    "good",
+		Title:    "Deploy",
+		Fields: []AttachmentField{
+			{Title: "Environment", Value: "prod", Short: true},
+			{Title: "Notes", Value: "No downtime"},
+		},
+		Footer: "deploybot",
+		Ts:     1700000000,
+	}
+	if _, err := bot.PostMessage(PostMessageParameters{Channel: "C1", Attachments: []Attachment{attachment}}); err != nil {
+		t.Fatal(err)
+	}
+	var body struct{ Attachments []interface{} }
+	json.Unmarshal(api.callsTo("chat.postMessage")[0].Body, &body)
+	var want []interface{}
+	json.Unmarshal([]byte(`[{
+		"fallback": "Deploy finished",
+		"color": "good",
+		"title": "Deploy",
+		"fields": [
+			{"title": "Environment", "value": "prod", "short": true},
+			{"title": "Notes", "value": "No downtime", "short": false}
+		],
+		"footer": "deploybot",
+		"ts": 1700000000
+	}]`), &want)
+	if !reflect.DeepEqual(body.Attachments, want) {
+		t.Errorf("attachments serialized as %s", api.callsTo("chat.postMessage")[0].Body)
+	}
+}
+
+func TestSendMeMessage(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.meMessage", `{"ok":true,"channel":"C1","ts":"1.5"}`)
+	var sentTs string
+	bot.OnMessageSent = func(channel, text, ts string) { sentTs = ts }
+	if err := bot.SendMeMessage("C1", "waves"); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("chat.meMessage")
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls to chat.meMessage, want 1", len(calls))
+	}
+	if form := calls[0].Form; form.Get("channel") != "C1" || form.Get("text") != "waves" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+	if sentTs != "1.5" {
+		t.Errorf("OnMessageSent got timestamp %q", sentTs)
+	}
+	bot.ValidateFormatting = true
+	if _, ok := bot.SendMeMessage("C1", "waves at <@U1").(FormattingError); !ok {
+		t.Error("malformed message not flagged")
+	}
+	api.reply("chat.meMessage", `{"ok":false,"error":"channel_not_found"}`)
+	if apiErr, ok := bot.SendMeMessage("CNOPE", "waves").(APIError); !ok || apiErr.Code != "channel_not_found" {
+		t.Error("channel_not_found not surfaced")
+	}
+	if calls := len(api.callsTo("chat.meMessage")); calls != 2 {
+		t.Errorf("got %d calls to chat.meMessage, want none for the malformed message", calls)
+	}
+}
@@ -0,0 +1,56 @@
+package slackbot
+
+import (
+	"errors"
+
+	"golang.org/x/net/websocket"
+)
+
+// conn is the connection on which the bot sends and receives RTM messages.
+// It is satisfied by the WebSocket connection to Slack, and allows it to be
+// substituted, e.g. by a fake connection when testing.
+type conn interface {
+	Send(v interface{}) error    // Sends a given value as JSON
+	Receive(v interface{}) error // Blocks until a message arrives and decodes it as JSON into a given value
+	Close() error
+}
+
+// wsConn is a conn backed by a WebSocket connection, sending and receiving
+// messages through a given codec.
+type wsConn struct {
+	ws    *websocket.Conn
+	codec websocket.Codec
+}
+
+func (c *wsConn) Send(v interface{}) error    { return c.codec.Send(c.ws, v) }
+func (c *wsConn) Receive(v interface{}) error { return c.codec.Receive(c.ws, v) }
+func (c *wsConn) Close() error                { return c.ws.Close() }
+
+// currentConn returns the connection currently used by the bot.
+func (bot *SlackBot) currentConn() conn {
+	bot.connMutex.Lock()
+	defer bot.connMutex.Unlock()
+	return bot.ws
+}
+
+// setConn replaces the connection used by the bot. If the bot has been
+// disconnected in the meantime, the connection is closed instead, so that
+// it is not left open on a bot that was shut down.
+func (bot *SlackBot) setConn(ws conn) error {
+	bot.connMutex.Lock()
+	defer bot.connMutex.Unlock()
+	if bot.disconnected {
+		ws.Close()
+		return errors.New("bot was disconnected while connecting")
+	}
+	bot.ws = ws
+	return nil
+}
+
+// isDisconnected reports whether the bot has been disconnected through
+// Disconnect, as opposed to having lost its connection.
+func (bot *SlackBot) isDisconnected() bool {
+	bot.connMutex.Lock()
+	defer bot.connMutex.Unlock()
+	return bot.disconnected
+}
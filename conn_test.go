@@ -0,0 +1,29 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeConnDrivesListenAndSend(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.OnMessage = func(event MessageIn) error {
+		return bot.SendMessage(event.Channel, "you said "+event.Text)
+	}
+	go bot.listen()
+	c.push(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0"}`)
+	reply := c.next(t)
+	if reply["type"] != "message" || reply["channel"] != "C1" || reply["text"] != "you said hi" {
+		t.Errorf("replied %v", reply)
+	}
+	if id, ok := reply["id"].(float64); !ok || id < 1 {
+		t.Errorf("reply sent without an ID: %v", reply)
+	}
+	// Losing the connection without AutoReconnect disconnects the bot
+	c.Close()
+	select {
+	case <-bot.Done:
+	case <-time.After(time.Second):
+		t.Fatal("bot not disconnected after losing its connection")
+	}
+}
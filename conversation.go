@@ -0,0 +1,89 @@
+package slackbot
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoAnswer is returned by Conversation.Ask when no answer arrives in time.
+var ErrNoAnswer = errors.New("no answer received")
+
+// Conversation is an exchange between the bot and a single user in a given
+// channel, allowing the bot to ask a question and wait for the answer, e.g.
+// from a callback, rather than keeping track of its state across callbacks.
+type Conversation struct {
+	Channel string
+	User    string
+	Timeout time.Duration // How long Ask waits for an answer; forever if zero
+
+	bot *SlackBot
+}
+
+// Converse starts a conversation with a given user in a given channel, waiting
+// up to a given timeout for each answer.
+func (bot *SlackBot) Converse(channel, user string, timeout time.Duration) *Conversation {
+	return &Conversation{Channel: channel, User: user, Timeout: timeout, bot: bot}
+}
+
+// Ask sends a given prompt to the channel of the conversation and waits for
+// the next message by its user in that channel, returning its text. That
+// message is passed to no callbacks, not even OnEvent, nor to WaitFor.
+// Hidden messages, such as edits, are not answers, and neither are messages
+// passed to a callback for their subtype. If no message arrives within the
+// timeout, ErrNoAnswer is returned. If DispatchSync is set, Ask must not be
+// called from a callback, since no answer can be received while the
+// callback waits.
+func (conversation *Conversation) Ask(prompt string) (answer string, err error) {
+	bot := conversation.bot
+	key := conversation.Channel + "/" + conversation.User
+	answers := make(chan MessageIn, 1)
+	bot.conversationMutex.Lock()
+	if bot.awaiting == nil {
+		bot.awaiting = make(map[string]chan MessageIn)
+	}
+	bot.awaiting[key] = answers
+	bot.conversationMutex.Unlock()
+	defer func() {
+		bot.conversationMutex.Lock()
+		if bot.awaiting[key] == answers {
+			delete(bot.awaiting, key)
+		}
+		bot.conversationMutex.Unlock()
+	}()
+	if err = bot.SendMessage(conversation.Channel, prompt); err != nil {
+		return
+	}
+	var timeout <-chan time.Time
+	if conversation.Timeout > 0 {
+		timer := time.NewTimer(conversation.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case message := <-answers:
+		return message.Text, nil
+	case <-timeout:
+		return "", ErrNoAnswer
+	}
+}
+
+// answer passes a message on to the conversation waiting for it, if any,
+// and reports whether there was one. Hidden messages and messages passed to
+// a callback for their subtype are not answers.
+func (bot *SlackBot) answer(event MessageIn) bool {
+	if event.Hidden || bot.routedBySubtype(event) {
+		return false
+	}
+	key := event.Channel + "/" + event.User
+	bot.conversationMutex.Lock()
+	answers, ok := bot.awaiting[key]
+	if ok {
+		delete(bot.awaiting, key)
+		answers <- event
+	}
+	bot.conversationMutex.Unlock()
+	if ok && bot.AutoMarkRead && event.Ts != "" {
+		bot.markRead(event.Channel, event.Ts)
+	}
+	return ok
+}
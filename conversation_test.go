@@ -0,0 +1,120 @@
+package slackbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConversationTwoSteps(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	others := make(chan MessageIn, 1)
+	bot.OnMessage = func(event MessageIn) error {
+		others <- event
+		return nil
+	}
+	type result struct {
+		answers []string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conversation := bot.Converse("C1", "U1", time.Second)
+		var r result
+		for _, prompt := range []string{"What environment?", "Which version?"} {
+			var answer string
+			if answer, r.err = conversation.Ask(prompt); r.err != nil {
+				break
+			}
+			r.answers = append(r.answers, answer)
+		}
+		done <- r
+	}()
+	if prompt := c.next(t); prompt["channel"] != "C1" || prompt["text"] != "What environment?" {
+		t.Fatalf("first prompt sent as %v", prompt)
+	}
+	// Messages by other users are not answers
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U2","text":"staging","ts":"1.0"}`))
+	select {
+	case event := <-others:
+		if event.Text != "staging" {
+			t.Errorf("OnMessage got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message by another user not passed to OnMessage")
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"prod","ts":"2.0"}`))
+	if prompt := c.next(t); prompt["text"] != "Which version?" {
+		t.Fatalf("second prompt sent as %v", prompt)
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"1.2.3","ts":"3.0"}`))
+	r := <-done
+	if r.err != nil || len(r.answers) != 2 || r.answers[0] != "prod" || r.answers[1] != "1.2.3" {
+		t.Errorf("got answers %q and error %v", r.answers, r.err)
+	}
+	select {
+	case event := <-others:
+		t.Errorf("answer %+v passed to OnMessage", event)
+	default:
+	}
+}
+
+func TestConversationTimeout(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	conversation := bot.Converse("C1", "U1", 20*time.Millisecond)
+	start := time.Now()
+	if _, err := conversation.Ask("Anyone there?"); err != ErrNoAnswer {
+		t.Fatalf("got error %v, want ErrNoAnswer", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("gave up after %v", elapsed)
+	}
+	c.next(t)
+	received := make(chan MessageIn, 1)
+	bot.OnMessage = func(event MessageIn) error {
+		received <- event
+		return nil
+	}
+	// A late answer goes to the callbacks as usual
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"yes","ts":"1.0"}`))
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Error("late answer not passed to OnMessage")
+	}
+}
+
+func TestConversationAnswerReachesNoCallbacks(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	var events []Event
+	bot.OnEvent = func(event Event) error {
+		events = append(events, event)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waited := make(chan Event, 1)
+	go func() {
+		event, _ := bot.WaitFor(ctx, func(event Event) bool { return true })
+		waited <- event
+	}()
+	eventually(t, func() bool { return waiting(bot) == 1 }, "WaitFor not waiting")
+	answered := make(chan string, 1)
+	go func() {
+		answer, _ := bot.Converse("C1", "U1", time.Second).Ask("What environment?")
+		answered <- answer
+	}()
+	c.next(t)
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"prod","ts":"1.0"}`))
+	if answer := <-answered; answer != "prod" {
+		t.Errorf("got answer %q", answer)
+	}
+	if len(events) != 0 {
+		t.Errorf("OnEvent got %+v", events)
+	}
+	select {
+	case event := <-waited:
+		t.Errorf("WaitFor got %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,382 @@
+package slackbot
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// historyPageSize is the number of items requested per page when
+// paginating through conversation history, replies, or members.
+const historyPageSize = 200
+
+// messagesResponse represents a response sent by the Slack Web API methods
+// conversations.history and conversations.replies. They are documented at
+// https://api.slack.com/methods/conversations.history and https://api.slack.com/methods/conversations.replies
+type messagesResponse struct {
+	apiResponse
+	Messages         []MessageIn      `json:"messages"`
+	HasMore          bool             `json:"has_more"`
+	ResponseMetadata responseMetadata `json:"response_metadata"`
+}
+
+// History returns up to limit of the most recent messages in a given channel,
+// newest first, following pagination as needed. A limit of zero or less
+// fetches the entire history. If the bot is not a member of the channel,
+// the returned error is an APIError with code "not_in_channel".
+func (bot *SlackBot) History(channel string, limit int) (messages []MessageIn, err error) {
+	return bot.history(channel, "", limit)
+}
+
+// history returns up to limit of the most recent messages in a given channel,
+// or all of them if limit is zero or less, newest first. If oldest is not
+// empty, only messages posted after the message with that timestamp are
+// returned.
+func (bot *SlackBot) history(channel, oldest string, limit int) (messages []MessageIn, err error) {
+	cursor := ""
+	for {
+		pageSize := historyPageSize
+		if limit > 0 && limit-len(messages) < pageSize {
+			pageSize = limit - len(messages)
+		}
+		params := url.Values{
+			"channel": {channel},
+			"limit":   {strconv.Itoa(pageSize)},
+		}
+		if oldest != "" {
+			params.Set("oldest", oldest)
+		}
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+		var resp messagesResponse
+		if err = bot.callAPI("conversations.history", params, &resp); err != nil {
+			return
+		}
+		for _, message := range resp.Messages {
+			// Messages in the history do not carry their channel
+			message.Channel = channel
+			messages = append(messages, message)
+		}
+		cursor = resp.ResponseMetadata.NextCursor
+		if !resp.HasMore || cursor == "" || (limit > 0 && len(messages) >= limit) {
+			break
+		}
+	}
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return
+}
+
+// ErrMessageNotFound is returned by GetMessage when there is no message
+// with the given timestamp.
+var ErrMessageNotFound = errors.New("message not found")
+
+// GetMessage returns the message with a given timestamp in a given channel,
+// or ErrMessageNotFound if there is none. Replies in threads are only found
+// if they were also sent to the channel; otherwise, use Replies.
+func (bot *SlackBot) GetMessage(channel, ts string) (message MessageIn, err error) {
+	params := url.Values{
+		"channel":   {channel},
+		"latest":    {ts},
+		"oldest":    {ts},
+		"inclusive": {"true"},
+		"limit":     {"1"},
+	}
+	var resp messagesResponse
+	if err = bot.callAPI("conversations.history", params, &resp); err != nil {
+		return
+	}
+	if len(resp.Messages) == 0 || resp.Messages[0].Ts != ts {
+		return message, ErrMessageNotFound
+	}
+	message = resp.Messages[0]
+	message.Channel = channel
+	return message, nil
+}
+
+// Replies returns all messages in the thread of a given channel whose parent
+// message has timestamp threadTs, starting with the parent message itself,
+// following pagination as needed.
+func (bot *SlackBot) Replies(channel, threadTs string) (messages []MessageIn, err error) {
+	cursor := ""
+	for {
+		var page []MessageIn
+		if page, cursor, err = bot.RepliesPage(channel, threadTs, cursor, historyPageSize); err != nil {
+			return
+		}
+		messages = append(messages, page...)
+		if cursor == "" {
+			return
+		}
+	}
+}
+
+// RepliesPage returns a single page of up to limit messages in the thread
+// of a given channel whose parent message has timestamp threadTs, starting
+// at a given cursor, or at the parent message if the cursor is empty. The
+// returned cursor continues with the next page, and is empty if there are
+// no more messages. A limit of zero or less lets Slack choose the page size.
+func (bot *SlackBot) RepliesPage(channel, threadTs, cursor string, limit int) (messages []MessageIn, nextCursor string, err error) {
+	params := url.Values{
+		"channel": {channel},
+		"ts":      {threadTs},
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	var resp messagesResponse
+	if err = bot.callAPI("conversations.replies", params, &resp); err != nil {
+		return
+	}
+	for _, message := range resp.Messages {
+		message.Channel = channel
+		messages = append(messages, message)
+	}
+	if resp.HasMore {
+		nextCursor = resp.ResponseMetadata.NextCursor
+	}
+	return
+}
+
+// SetTopic sets the topic of a given channel. The bot must be a member of
+// the channel; otherwise the returned error is an APIError with code "not_in_channel".
+// Slack API doc: https://api.slack.com/methods/conversations.setTopic
+func (bot *SlackBot) SetTopic(channel, topic string) error {
+	params := url.Values{
+		"channel": {channel},
+		"topic":   {topic},
+	}
+	var resp apiResponse
+	return bot.callAPI("conversations.setTopic", params, &resp)
+}
+
+// SetPurpose sets the purpose of a given channel. The bot must be a member of
+// the channel; otherwise the returned error is an APIError with code "not_in_channel".
+// Slack API doc: https://api.slack.com/methods/conversations.setPurpose
+func (bot *SlackBot) SetPurpose(channel, purpose string) error {
+	params := url.Values{
+		"channel": {channel},
+		"purpose": {purpose},
+	}
+	var resp apiResponse
+	return bot.callAPI("conversations.setPurpose", params, &resp)
+}
+
+// LeaveChannel makes the bot leave a given channel. Leaving a channel that
+// the bot is not a member of is not considered an error.
+// Slack API doc: https://api.slack.com/methods/conversations.leave
+func (bot *SlackBot) LeaveChannel(channel string) error {
+	var resp apiResponse
+	err := bot.callAPI("conversations.leave", url.Values{"channel": {channel}}, &resp)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code == "not_in_channel" {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if cached, ok := bot.CachedChannel(channel); ok {
+		cached.IsMember = false
+		bot.cacheChannel(cached)
+	}
+	return nil
+}
+
+// ArchiveChannel archives a given channel. Archiving a channel that is
+// already archived is not considered an error.
+// Slack API doc: https://api.slack.com/methods/conversations.archive
+func (bot *SlackBot) ArchiveChannel(channel string) error {
+	return bot.setArchived("conversations.archive", "already_archived", channel, true)
+}
+
+// UnarchiveChannel unarchives a given channel. Unarchiving a channel that is
+// not archived is not considered an error.
+// Slack API doc: https://api.slack.com/methods/conversations.unarchive
+func (bot *SlackBot) UnarchiveChannel(channel string) error {
+	return bot.setArchived("conversations.unarchive", "not_archived", channel, false)
+}
+
+// setArchived calls a given Web API method archiving or unarchiving a
+// channel, ignoring the error code meaning that there was nothing to do,
+// and updates the cache accordingly.
+func (bot *SlackBot) setArchived(method, unchanged, channel string, archived bool) error {
+	var resp apiResponse
+	err := bot.callAPI(method, url.Values{"channel": {channel}}, &resp)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code == unchanged {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if cached, ok := bot.CachedChannel(channel); ok {
+		cached.IsArchived = archived
+		bot.cacheChannel(cached)
+	}
+	return nil
+}
+
+// ErrChannelNameTaken is returned by CreateChannel when a channel with the
+// given name already exists, whether or not the bot can see it.
+var ErrChannelNameTaken = errors.New("channel name already taken")
+
+// createResponse represents a response sent by the Slack Web API method
+// conversations.create. It is documented at https://api.slack.com/methods/conversations.create
+type createResponse struct {
+	apiResponse
+	Channel Channel `json:"channel"`
+}
+
+// CreateChannel creates a public or private channel with a given name, of
+// which the bot becomes a member, and returns its ID. If the name is in
+// use, ErrChannelNameTaken is returned.
+func (bot *SlackBot) CreateChannel(name string, isPrivate bool) (channelID string, err error) {
+	params := url.Values{
+		"name":       {name},
+		"is_private": {strconv.FormatBool(isPrivate)},
+	}
+	var resp createResponse
+	err = bot.callAPI("conversations.create", params, &resp)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code == "name_taken" {
+		return "", ErrChannelNameTaken
+	}
+	if err != nil {
+		return
+	}
+	resp.Channel.IsMember = true
+	bot.cacheChannel(resp.Channel)
+	return resp.Channel.ID, nil
+}
+
+// inviteResponse represents a response sent by the Slack Web API method
+// conversations.invite. It is documented at https://api.slack.com/methods/conversations.invite
+type inviteResponse struct {
+	apiResponse
+	Errors []InviteFailure `json:"errors"`
+}
+
+// InviteFailure describes a user that could not be invited to a channel.
+type InviteFailure struct {
+	User string `json:"user"`
+	Code string `json:"error"` // E.g. "cant_invite_self" or "already_in_channel"
+}
+
+// InviteError is returned by InviteUsers when some of the users could not
+// be invited. The remaining users were invited.
+type InviteError struct {
+	Failures []InviteFailure
+}
+
+func (err InviteError) Error() string {
+	failures := make([]string, len(err.Failures))
+	for i, failure := range err.Failures {
+		failures[i] = failure.User + ": " + failure.Code
+	}
+	return "could not invite users: " + strings.Join(failures, ", ")
+}
+
+// InviteUsers invites the users with the given IDs to a given channel. Users
+// are invited even if others on the list cannot be, in which case the
+// returned error is an InviteError describing those that failed.
+func (bot *SlackBot) InviteUsers(channel string, users []string) error {
+	params := url.Values{
+		"channel": {channel},
+		"users":   {strings.Join(users, ",")},
+		"force":   {"true"},
+	}
+	var resp inviteResponse
+	err := bot.callAPI("conversations.invite", params, &resp)
+	if len(resp.Errors) > 0 {
+		return InviteError{Failures: resp.Errors}
+	}
+	return err
+}
+
+// KickUser removes the user with a given ID from a given channel.
+// Slack API doc: https://api.slack.com/methods/conversations.kick
+func (bot *SlackBot) KickUser(channel, user string) error {
+	params := url.Values{
+		"channel": {channel},
+		"user":    {user},
+	}
+	var resp apiResponse
+	return bot.callAPI("conversations.kick", params, &resp)
+}
+
+// membersResponse represents a response sent by the Slack Web API method
+// conversations.members. It is documented at https://api.slack.com/methods/conversations.members
+type membersResponse struct {
+	apiResponse
+	Members          []string         `json:"members"`
+	ResponseMetadata responseMetadata `json:"response_metadata"`
+}
+
+// ChannelMembers returns the IDs of all members of a given channel, following
+// pagination as needed.
+func (bot *SlackBot) ChannelMembers(channel string) (members []string, err error) {
+	cursor := ""
+	for {
+		params := url.Values{
+			"channel": {channel},
+			"limit":   {strconv.Itoa(historyPageSize)},
+		}
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+		var resp membersResponse
+		if err = bot.callAPI("conversations.members", params, &resp); err != nil {
+			return
+		}
+		members = append(members, resp.Members...)
+		cursor = resp.ResponseMetadata.NextCursor
+		if cursor == "" {
+			return
+		}
+	}
+}
+
+// openResponse represents a response sent by the Slack Web API method
+// conversations.open. It is documented at https://api.slack.com/methods/conversations.open
+type openResponse struct {
+	apiResponse
+	Channel Channel `json:"channel"`
+}
+
+// OpenDirectMessage returns the ID of the direct message channel between the
+// bot and a given user, reusing a cached channel if there is one, and otherwise
+// opening it through the Web API, after which it is cached.
+func (bot *SlackBot) OpenDirectMessage(userID string) (channel string, err error) {
+	bot.cacheMutex.RLock()
+	for _, cached := range bot.channels {
+		if cached.IsIM && cached.User == userID {
+			channel = cached.ID
+			break
+		}
+	}
+	bot.cacheMutex.RUnlock()
+	if channel != "" {
+		return
+	}
+	var resp openResponse
+	if err = bot.callAPI("conversations.open", url.Values{"users": {userID}}, &resp); err != nil {
+		return
+	}
+	// The channel returned may only include its ID
+	resp.Channel.IsIM = true
+	resp.Channel.User = userID
+	bot.cacheChannel(resp.Channel)
+	return resp.Channel.ID, nil
+}
+
+// SendDirectMessage sends a given message to a given user as a direct message.
+func (bot *SlackBot) SendDirectMessage(userID, message string) error {
+	channel, err := bot.OpenDirectMessage(userID)
+	if err != nil {
+		return err
+	}
+	return bot.SendMessage(channel, message)
+}
@@ -0,0 +1,350 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistoryPagination(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("conversations.history", func(call apiCall) interface{} {
+		if call.Form.Get("channel") != "C1" {
+			t.Errorf("history requested for channel %q", call.Form.Get("channel"))
+		}
+		if call.Form.Get("cursor") == "" {
+			return `{"ok":true,"messages":[{"type":"message","user":"U1","text":"third","ts":"3.0"},
+				{"type":"message","user":"U2","text":"second","ts":"2.0"}],
+				"has_more":true,"response_metadata":{"next_cursor":"page2"}}`
+		}
+		return `{"ok":true,"messages":[{"type":"message","user":"U1","text":"first","ts":"1.0","thread_ts":"1.0"}],
+			"has_more":false}`
+	})
+	messages, err := bot.History("C1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(messages))
+	}
+	for i, want := range []string{"third", "second", "first"} {
+		if messages[i].Text != want || messages[i].Channel != "C1" {
+			t.Errorf("message %d is %+v, want text %q in C1", i, messages[i], want)
+		}
+	}
+	if messages[0].User != "U1" || messages[2].ThreadTs != "1.0" {
+		t.Errorf("fields not parsed: %+v", messages)
+	}
+	if calls := api.callsTo("conversations.history"); len(calls) != 2 || calls[1].Form.Get("cursor") != "page2" {
+		t.Errorf("expected two requests following the cursor, got %+v", calls)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.history", `{"ok":true,"messages":[{"text":"b","ts":"2.0"},{"text":"a","ts":"1.0"}],
+		"has_more":true,"response_metadata":{"next_cursor":"more"}}`)
+	messages, err := bot.History("C1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || len(api.callsTo("conversations.history")) != 1 {
+		t.Errorf("got %d messages in %d requests, want 2 in 1", len(messages), len(api.callsTo("conversations.history")))
+	}
+	if limit := api.callsTo("conversations.history")[0].Form.Get("limit"); limit != "2" {
+		t.Errorf("requested limit %s, want 2", limit)
+	}
+}
+
+func TestHistoryNotInChannel(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.history", `{"ok":false,"error":"not_in_channel"}`)
+	_, err := bot.History("C1", 0)
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code != "not_in_channel" {
+		t.Errorf("got error %v, want APIError not_in_channel", err)
+	}
+}
+
+func TestRepliesPagination(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("conversations.replies", func(call apiCall) interface{} {
+		if call.Form.Get("ts") != "1.0" {
+			t.Errorf("replies requested for thread %q", call.Form.Get("ts"))
+		}
+		if call.Form.Get("cursor") == "" {
+			return `{"ok":true,"messages":[{"user":"U1","text":"question?","ts":"1.0","thread_ts":"1.0","reply_count":3},
+				{"user":"U2","text":"yes","ts":"2.0","thread_ts":"1.0","parent_user_id":"U1"}],
+				"has_more":true,"response_metadata":{"next_cursor":"page2"}}`
+		}
+		return `{"ok":true,"messages":[{"user":"U3","text":"no","ts":"3.0","thread_ts":"1.0","parent_user_id":"U1"},
+			{"user":"U4","text":"maybe","ts":"4.0","thread_ts":"1.0","parent_user_id":"U1"}],"has_more":false}`
+	})
+	messages, err := bot.Replies("C1", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want 4", len(messages))
+	}
+	if messages[0].IsThreadReply() || messages[0].ReplyCount != 3 {
+		t.Errorf("parent parsed as %+v", messages[0])
+	}
+	for i, want := range []string{"yes", "no", "maybe"} {
+		reply := messages[i+1]
+		if reply.Text != want || !reply.IsThreadReply() || reply.Channel != "C1" {
+			t.Errorf("reply %d parsed as %+v", i, reply)
+		}
+	}
+	if calls := api.callsTo("conversations.replies"); len(calls) != 2 || calls[1].Form.Get("cursor") != "page2" {
+		t.Errorf("expected two requests following the cursor, got %+v", calls)
+	}
+}
+
+func TestSetTopicAndPurpose(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.setTopic", `{"ok":true}`)
+	api.reply("conversations.setPurpose", `{"ok":true}`)
+	if err := bot.SetTopic("C1", "Release day"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SetPurpose("C1", "Talk about releases"); err != nil {
+		t.Fatal(err)
+	}
+	if form := api.callsTo("conversations.setTopic")[0].Form; form.Get("channel") != "C1" || form.Get("topic") != "Release day" {
+		t.Errorf("unexpected topic parameters %v", form)
+	}
+	if form := api.callsTo("conversations.setPurpose")[0].Form; form.Get("channel") != "C1" || form.Get("purpose") != "Talk about releases" {
+		t.Errorf("unexpected purpose parameters %v", form)
+	}
+}
+
+func TestSetTopicNotInChannel(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.setTopic", `{"ok":false,"error":"not_in_channel"}`)
+	api.reply("conversations.setPurpose", `{"ok":false,"error":"not_in_channel"}`)
+	for _, err := range []error{bot.SetTopic("C1", "topic"), bot.SetPurpose("C1", "purpose")} {
+		if apiErr, ok := err.(APIError); !ok || apiErr.Code != "not_in_channel" {
+			t.Errorf("got error %v, want APIError not_in_channel", err)
+		}
+	}
+}
+
+func TestChannelMembersPagination(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("conversations.members", func(call apiCall) interface{} {
+		switch call.Form.Get("cursor") {
+		case "":
+			return `{"ok":true,"members":["U1","U2"],"response_metadata":{"next_cursor":"page2"}}`
+		case "page2":
+			return `{"ok":true,"members":["U3"],"response_metadata":{"next_cursor":"page3"}}`
+		}
+		return `{"ok":true,"members":["U4"],"response_metadata":{"next_cursor":""}}`
+	})
+	members, err := bot.ChannelMembers("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(members, ",") != "U1,U2,U3,U4" {
+		t.Errorf("got members %v", members)
+	}
+	if calls := api.callsTo("conversations.members"); len(calls) != 3 || calls[0].Form.Get("channel") != "C1" {
+		t.Errorf("unexpected requests %+v", calls)
+	}
+}
+
+func TestSendDirectMessage(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	api := newFakeAPI(t)
+	bot.APIURL = api.server.URL + "/api/"
+	api.reply("conversations.open", `{"ok":true,"channel":{"id":"D1"}}`)
+	for _, text := range []string{"hello", "again"} {
+		if err := bot.SendDirectMessage("U1", text); err != nil {
+			t.Fatal(err)
+		}
+		if sent := c.next(t); sent["channel"] != "D1" || sent["text"] != text {
+			t.Errorf("sent %v, want %s to D1", sent, text)
+		}
+	}
+	calls := api.callsTo("conversations.open")
+	if len(calls) != 1 || calls[0].Form.Get("users") != "U1" {
+		t.Errorf("opened direct messages as %+v, want once for U1", calls)
+	}
+}
+
+func TestRepliesPageCursor(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("conversations.replies", func(call apiCall) interface{} {
+		if call.Form.Get("cursor") == "" {
+			return `{"ok":true,"messages":[{"text":"parent","ts":"1.0","thread_ts":"1.0"},{"text":"first","ts":"2.0","thread_ts":"1.0"}],
+				"has_more":true,"response_metadata":{"next_cursor":"bmV4dA=="}}`
+		}
+		return `{"ok":true,"messages":[{"text":"second","ts":"3.0","thread_ts":"1.0"}],"has_more":false,
+			"response_metadata":{"next_cursor":""}}`
+	})
+	page, cursor, err := bot.RepliesPage("C1", "1.0", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || cursor != "bmV4dA==" {
+		t.Fatalf("got %d messages and cursor %q", len(page), cursor)
+	}
+	rest, cursor, err := bot.RepliesPage("C1", "1.0", cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 || rest[0].Text != "second" || cursor != "" {
+		t.Errorf("got %+v and cursor %q on the last page", rest, cursor)
+	}
+	calls := api.callsTo("conversations.replies")
+	if calls[0].Form.Get("limit") != "2" || calls[1].Form.Get("cursor") != "bmV4dA==" {
+		t.Errorf("unexpected requests %+v", calls)
+	}
+}
+
+func TestLeaveChannel(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.fillCaches(nil, []Channel{{ID: "C1", Name: "general", IsMember: true}})
+	api.reply("conversations.leave", `{"ok":true}`)
+	if err := bot.LeaveChannel("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if form := api.callsTo("conversations.leave")[0].Form; form.Get("channel") != "C1" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+	if channel, _ := bot.CachedChannel("C1"); channel.IsMember {
+		t.Error("cached channel still lists the bot as a member")
+	}
+	api.reply("conversations.leave", `{"ok":false,"error":"not_in_channel"}`)
+	if err := bot.LeaveChannel("C2"); err != nil {
+		t.Errorf("not_in_channel gave error %v", err)
+	}
+	api.reply("conversations.leave", `{"ok":false,"error":"cant_leave_general"}`)
+	if err := bot.LeaveChannel("C1"); err == nil {
+		t.Error("cant_leave_general gave no error")
+	}
+}
+
+func TestInviteUsers(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.invite", `{"ok":true,"channel":{"id":"C1"}}`)
+	if err := bot.InviteUsers("C1", []string{"U1", "U2"}); err != nil {
+		t.Fatal(err)
+	}
+	if form := api.callsTo("conversations.invite")[0].Form; form.Get("channel") != "C1" || form.Get("users") != "U1,U2" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+}
+
+func TestInviteUsersPartialFailure(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.invite", `{"ok":false,"error":"cant_invite_self",
+		"errors":[{"user":"UBOT","ok":false,"error":"cant_invite_self"}]}`)
+	err := bot.InviteUsers("C1", []string{"U1", "UBOT"})
+	inviteErr, ok := err.(InviteError)
+	if !ok || len(inviteErr.Failures) != 1 || inviteErr.Failures[0] != (InviteFailure{User: "UBOT", Code: "cant_invite_self"}) {
+		t.Fatalf("got error %#v", err)
+	}
+	if err.Error() != "could not invite users: UBOT: cant_invite_self" {
+		t.Errorf("error described as %q", err)
+	}
+}
+
+func TestKickUser(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.kick", `{"ok":true}`)
+	if err := bot.KickUser("C1", "U1"); err != nil {
+		t.Fatal(err)
+	}
+	if form := api.callsTo("conversations.kick")[0].Form; form.Get("channel") != "C1" || form.Get("user") != "U1" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+	api.reply("conversations.kick", `{"ok":false,"error":"cant_kick_self"}`)
+	if apiErr, ok := bot.KickUser("C1", "UBOT").(APIError); !ok || apiErr.Code != "cant_kick_self" {
+		t.Error("cant_kick_self not surfaced")
+	}
+}
+
+func TestCreateChannel(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.create", `{"ok":true,"channel":{"id":"C9","name":"incident-42","is_private":true}}`)
+	id, err := bot.CreateChannel("incident-42", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "C9" {
+		t.Errorf("got channel ID %q, want C9", id)
+	}
+	if form := api.callsTo("conversations.create")[0].Form; form.Get("name") != "incident-42" || form.Get("is_private") != "true" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+	if cached, ok := bot.CachedChannel("C9"); !ok || cached.Name != "incident-42" || !cached.IsMember {
+		t.Errorf("created channel cached as %+v", cached)
+	}
+	api.reply("conversations.create", `{"ok":false,"error":"name_taken"}`)
+	if _, err := bot.CreateChannel("incident-42", false); err != ErrChannelNameTaken {
+		t.Errorf("got error %v, want ErrChannelNameTaken", err)
+	}
+}
+
+func TestArchiveAndUnarchiveChannel(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.fillCaches(nil, []Channel{{ID: "C1", Name: "old-project"}})
+	api.reply("conversations.archive", `{"ok":true}`)
+	api.reply("conversations.unarchive", `{"ok":true}`)
+	if err := bot.ArchiveChannel("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := bot.CachedChannel("C1"); !cached.IsArchived {
+		t.Error("archived channel not marked as archived in the cache")
+	}
+	if err := bot.UnarchiveChannel("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := bot.CachedChannel("C1"); cached.IsArchived {
+		t.Error("unarchived channel still marked as archived in the cache")
+	}
+	for _, method := range []string{"conversations.archive", "conversations.unarchive"} {
+		if form := api.callsTo(method)[0].Form; form.Get("channel") != "C1" {
+			t.Errorf("%s called with parameters %v", method, form)
+		}
+	}
+	api.reply("conversations.archive", `{"ok":false,"error":"already_archived"}`)
+	api.reply("conversations.unarchive", `{"ok":false,"error":"not_archived"}`)
+	if err := bot.ArchiveChannel("C1"); err != nil {
+		t.Errorf("already_archived gave error %v", err)
+	}
+	if err := bot.UnarchiveChannel("C1"); err != nil {
+		t.Errorf("not_archived gave error %v", err)
+	}
+	api.reply("conversations.archive", `{"ok":false,"error":"cant_archive_general"}`)
+	if apiErr, ok := bot.ArchiveChannel("C1").(APIError); !ok || apiErr.Code != "cant_archive_general" {
+		t.Error("cant_archive_general not surfaced")
+	}
+}
+
+func TestGetMessage(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("conversations.history", `{"ok":true,"has_more":false,"messages":[
+		{"type":"message","user":"U1","text":"the original","ts":"1512085950.000216"}]}`)
+	message, err := bot.GetMessage("C1", "1512085950.000216")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Channel != "C1" || message.User != "U1" || message.Text != "the original" || message.Ts != "1512085950.000216" {
+		t.Errorf("message parsed as %+v", message)
+	}
+	form := api.callsTo("conversations.history")[0].Form
+	for name, want := range map[string]string{"channel": "C1", "latest": "1512085950.000216", "oldest": "1512085950.000216", "inclusive": "true", "limit": "1"} {
+		if got := form.Get(name); got != want {
+			t.Errorf("%s sent as %q, want %q", name, got, want)
+		}
+	}
+	api.reply("conversations.history", `{"ok":true,"has_more":false,"messages":[]}`)
+	if _, err := bot.GetMessage("C1", "1.0"); err != ErrMessageNotFound {
+		t.Errorf("got error %v, want ErrMessageNotFound", err)
+	}
+	// A different message is not the one asked for
+	api.reply("conversations.history", `{"ok":true,"has_more":false,"messages":[{"type":"message","text":"other","ts":"0.5"}]}`)
+	if _, err := bot.GetMessage("C1", "1.0"); err != ErrMessageNotFound {
+		t.Errorf("got error %v, want ErrMessageNotFound", err)
+	}
+}
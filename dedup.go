@@ -0,0 +1,54 @@
+package slackbot
+
+import "sync"
+
+// dedupCache remembers the keys of the most recently seen messages, up to
+// a fixed number, in order to detect messages delivered more than once.
+type dedupCache struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+	keys  []string // Ring buffer of remembered keys, oldest at next
+	next  int
+}
+
+// isDuplicate reports whether a message with a given key was already seen
+// among the last window messages, and remembers the key otherwise.
+func (cache *dedupCache) isDuplicate(key string, window int) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.seen == nil {
+		cache.seen = make(map[string]bool, window)
+		cache.keys = make([]string, window)
+	}
+	if cache.seen[key] {
+		return true
+	}
+	delete(cache.seen, cache.keys[cache.next])
+	cache.keys[cache.next] = key
+	cache.seen[key] = true
+	cache.next = (cache.next + 1) % len(cache.keys)
+	return false
+}
+
+// dedupKey returns the key identifying a message for deduplication, or the
+// empty string if the message cannot be identified. The client_msg_id is
+// used when present, as it is the same however the message is delivered.
+func (event MessageIn) dedupKey() string {
+	if event.ClientMsgID != "" {
+		return event.ClientMsgID
+	}
+	if event.Ts == "" {
+		return ""
+	}
+	return event.Channel + "/" + event.Ts
+}
+
+// isDuplicateMessage reports whether deduplication is enabled and a given
+// message has already been handled.
+func (bot *SlackBot) isDuplicateMessage(event MessageIn) bool {
+	key := event.dedupKey()
+	if bot.DedupWindow <= 0 || key == "" {
+		return false
+	}
+	return bot.dedup.isDuplicate(key, bot.DedupWindow)
+}
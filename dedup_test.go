@@ -0,0 +1,60 @@
+package slackbot
+
+import "testing"
+
+func TestDedupDropsRedeliveredMessages(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.DedupWindow = 10
+	calls, events := 0, 0
+	bot.OnMessage = func(event MessageIn) error {
+		calls++
+		return nil
+	}
+	bot.OnEvent = func(event Event) error {
+		events++
+		return nil
+	}
+	message := []byte(`{"type":"message","channel":"C1","user":"U1","text":"deploy","ts":"1.0","client_msg_id":"m1"}`)
+	bot.handleEvent(message)
+	bot.handleEvent(message)
+	if calls != 1 || events != 1 {
+		t.Errorf("OnMessage called %d times and OnEvent %d times, want once each", calls, events)
+	}
+	// Without a client_msg_id, messages are identified by channel and ts
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"a","ts":"2.0"}`))
+	bot.handleEvent([]byte(`{"type":"message","channel":"C2","user":"U1","text":"b","ts":"2.0"}`))
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"a","ts":"2.0"}`))
+	if calls != 3 {
+		t.Errorf("callback called %d times, want 3", calls)
+	}
+}
+
+func TestDedupDisabledByDefault(t *testing.T) {
+	bot, _ := newTestBot(t)
+	calls := 0
+	bot.OnMessage = func(event MessageIn) error {
+		calls++
+		return nil
+	}
+	message := []byte(`{"type":"message","channel":"C1","user":"U1","text":"deploy","ts":"1.0"}`)
+	bot.handleEvent(message)
+	bot.handleEvent(message)
+	if calls != 2 {
+		t.Errorf("callback called %d times, want 2", calls)
+	}
+}
+
+func TestDedupWindowSlides(t *testing.T) {
+	var cache dedupCache
+	for _, key := range []string{"a", "b", "c"} {
+		if cache.isDuplicate(key, 2) {
+			t.Errorf("%s reported as a duplicate", key)
+		}
+	}
+	if cache.isDuplicate("a", 2) {
+		t.Error("a still remembered after leaving the window")
+	}
+	if !cache.isDuplicate("c", 2) {
+		t.Error("c not remembered")
+	}
+}
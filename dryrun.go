@@ -0,0 +1,42 @@
+package slackbot
+
+import "time"
+
+// SentMessage is a message that the bot would have sent in dry-run mode.
+type SentMessage struct {
+	Time    time.Time
+	Channel string // The channel, or the URL for responses sent through RespondURL
+	Text    string
+	PostAt  time.Time // The time at which a message scheduled through ScheduleMessage would have been posted; zero for other messages
+}
+
+// recordDryRun logs and records a message that would have been sent, had
+// the bot not been in dry-run mode.
+func (bot *SlackBot) recordDryRun(channel, text string) {
+	bot.logger.Printf("Dry run: not sending message %s to channel %s\n", text, channel)
+	bot.addDryRun(SentMessage{Time: time.Now(), Channel: channel, Text: text})
+	bot.messageSent(channel, text, "")
+}
+
+// recordDryRunScheduled logs and records a message that would have been
+// scheduled, had the bot not been in dry-run mode. As the message would not
+// have been posted yet, it is not treated as sent.
+func (bot *SlackBot) recordDryRunScheduled(channel, text string, postAt time.Time) {
+	bot.logger.Printf("Dry run: not scheduling message %s to channel %s at %v\n", text, channel, postAt)
+	bot.addDryRun(SentMessage{Time: time.Now(), Channel: channel, Text: text, PostAt: postAt})
+}
+
+// addDryRun adds a given message to those returned by SentMessages.
+func (bot *SlackBot) addDryRun(message SentMessage) {
+	bot.dryRunMutex.Lock()
+	defer bot.dryRunMutex.Unlock()
+	bot.dryRunMessages = append(bot.dryRunMessages, message)
+}
+
+// SentMessages returns the messages that the bot would have sent while
+// in dry-run mode, oldest first.
+func (bot *SlackBot) SentMessages() []SentMessage {
+	bot.dryRunMutex.Lock()
+	defer bot.dryRunMutex.Unlock()
+	return append([]SentMessage(nil), bot.dryRunMessages...)
+}
@@ -0,0 +1,85 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDryRunRecordsWithoutSending(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	api := newFakeAPI(t)
+	bot.APIURL = api.server.URL + "/api/"
+	bot.DryRun = true
+	bot.DispatchSync = true
+	bot.OnMessage = func(event MessageIn) error {
+		if err := bot.SendMessage(event.Channel, "pong"); err != nil {
+			return err
+		}
+		_, err := bot.PostMessage(PostMessageParameters{Channel: event.Channel, Text: "posted"})
+		return err
+	}
+	bot.OnCallbackError = func(err error) { t.Error(err) }
+	go bot.listen()
+	c.push(`{"type":"message","channel":"C1","user":"U1","text":"ping"}`)
+	eventually(t, func() bool { return len(bot.SentMessages()) == 2 }, "sent messages not recorded")
+	sent := bot.SentMessages()
+	if sent[0].Channel != "C1" || sent[0].Text != "pong" || sent[1].Text != "posted" || sent[0].Time.IsZero() {
+		t.Errorf("recorded %+v", sent)
+	}
+	select {
+	case message := <-c.sent:
+		t.Errorf("message written to the socket: %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if calls := api.callsTo("chat.postMessage"); len(calls) != 0 {
+		t.Errorf("message posted through the Web API")
+	}
+}
+
+func TestDryRunRespondURL(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.DryRun = true
+	var audited []string
+	bot.OnMessageSent = func(channel, text, ts string) { audited = append(audited, channel+" "+text) }
+	if err := bot.RespondURL("https://hooks.slack.com/actions/T1/1/abc", Response{Text: "done"}); err != nil {
+		t.Fatal(err)
+	}
+	sent := bot.SentMessages()
+	if len(sent) != 1 || sent[0].Channel != "https://hooks.slack.com/actions/T1/1/abc" || sent[0].Text != "done" {
+		t.Errorf("recorded %+v", sent)
+	}
+	if len(audited) != 1 || audited[0] != "https://hooks.slack.com/actions/T1/1/abc done" {
+		t.Errorf("OnMessageSent called with %v", audited)
+	}
+}
+
+func TestDryRunScheduleMessage(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.DryRun = true
+	bot.EchoSentMessages = true
+	bot.DispatchSync = true
+	bot.OnMessageSent = func(channel, text, ts string) { t.Errorf("OnMessageSent called for scheduled message %q", text) }
+	bot.OnMessage = func(event MessageIn) error {
+		t.Errorf("scheduled message %q echoed", event.Text)
+		return nil
+	}
+	postAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if _, err := bot.ScheduleMessage("C1", "later", postAt); err != nil {
+		t.Fatal(err)
+	}
+	sent := bot.SentMessages()
+	if len(sent) != 1 || sent[0].Channel != "C1" || sent[0].Text != "later" || !sent[0].PostAt.Equal(postAt) {
+		t.Errorf("recorded %+v", sent)
+	}
+}
+
+func TestDryRunDeleteScheduledMessage(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.DryRun = true
+	if err := bot.DeleteScheduledMessage("C1", "Q1298393284"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.callsTo("chat.deleteScheduledMessage"); len(calls) != 0 {
+		t.Errorf("scheduled message deleted in dry-run mode: %+v", calls)
+	}
+}
@@ -0,0 +1,195 @@
+package slackbot
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// shortcodePattern matches emoji shortcodes such as :tada: or :+1:.
+var shortcodePattern = regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+
+// ConvertEmoji replaces the shortcodes of common emoji in a given text, such
+// as :tada:, by the corresponding Unicode characters, for use where Slack does
+// not render shortcodes itself, e.g. in file titles. Unknown shortcodes,
+// including those of custom emoji, are left unchanged.
+func ConvertEmoji(text string) string {
+	return shortcodePattern.ReplaceAllStringFunc(text, func(shortcode string) string {
+		if emoji, ok := emojiByShortcode[shortcode[1:len(shortcode)-1]]; ok {
+			return emoji
+		}
+		return shortcode
+	})
+}
+
+// emojiListResponse represents a response sent by the Slack Web API method
+// emoji.list. It is documented at https://api.slack.com/methods/emoji.list
+type emojiListResponse struct {
+	apiResponse
+	Emoji map[string]string `json:"emoji"`
+}
+
+// Emoji returns the custom emoji of the workspace, mapping their names to
+// the URLs of their images, or to "alias:" followed by the name of another
+// emoji for aliases. The emoji are fetched once and cached until Slack
+// reports a change.
+func (bot *SlackBot) Emoji() (emoji map[string]string, err error) {
+	bot.emojiMutex.Lock()
+	defer bot.emojiMutex.Unlock()
+	if bot.emoji == nil {
+		var resp emojiListResponse
+		if err = bot.callAPI("emoji.list", url.Values{}, &resp); err != nil {
+			return
+		}
+		bot.emoji = resp.Emoji
+		if bot.emoji == nil {
+			bot.emoji = make(map[string]string)
+		}
+	}
+	// Return a copy so that the cache cannot be modified by the caller
+	emoji = make(map[string]string, len(bot.emoji))
+	for name, value := range bot.emoji {
+		emoji[name] = value
+	}
+	return
+}
+
+// emojiChanged represents the event sent when a custom emoji is added,
+// removed, or renamed.
+// Slack API doc: https://api.slack.com/events/emoji_changed
+type emojiChanged struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"` // Either "add", "remove", or "rename"
+}
+
+// EventType returns "emoji_changed".
+func (event emojiChanged) EventType() string { return "emoji_changed" }
+
+func (event emojiChanged) invoke(bot *SlackBot) (err error) {
+	bot.emojiMutex.Lock()
+	bot.emoji = nil
+	bot.emojiMutex.Unlock()
+	return nil
+}
+
+// emojiByShortcode maps the names of common emoji, as used by Slack, to
+// their Unicode representation.
+var emojiByShortcode = map[string]string{
+	"+1":                         "\U0001F44D",
+	"-1":                         "\U0001F44E",
+	"100":                        "\U0001F4AF",
+	"alarm_clock":                "⏰",
+	"angry":                      "\U0001F620",
+	"arrow_down":                 "⬇️",
+	"arrow_left":                 "⬅️",
+	"arrow_right":                "➡️",
+	"arrow_up":                   "⬆️",
+	"balloon":                    "\U0001F388",
+	"beer":                       "\U0001F37A",
+	"bell":                       "\U0001F514",
+	"blush":                      "\U0001F60A",
+	"bomb":                       "\U0001F4A3",
+	"books":                      "\U0001F4DA",
+	"boom":                       "\U0001F4A5",
+	"bug":                        "\U0001F41B",
+	"bulb":                       "\U0001F4A1",
+	"cake":                       "\U0001F370",
+	"calendar":                   "\U0001F4C6",
+	"chart_with_downwards_trend": "\U0001F4C9",
+	"chart_with_upwards_trend":   "\U0001F4C8",
+	"clap":                       "\U0001F44F",
+	"clipboard":                  "\U0001F4CB",
+	"cloud":                      "☁️",
+	"coffee":                     "☕",
+	"confused":                   "\U0001F615",
+	"construction":               "\U0001F6A7",
+	"cry":                        "\U0001F622",
+	"crystal_ball":               "\U0001F52E",
+	"disappointed":               "\U0001F61E",
+	"dog":                        "\U0001F436",
+	"email":                      "\U0001F4E7",
+	"exclamation":                "❗",
+	"eyes":                       "\U0001F440",
+	"fire":                       "\U0001F525",
+	"flushed":                    "\U0001F633",
+	"gear":                       "⚙️",
+	"ghost":                      "\U0001F47B",
+	"gift":                       "\U0001F381",
+	"grimacing":                  "\U0001F62C",
+	"grin":                       "\U0001F601",
+	"grinning":                   "\U0001F600",
+	"hammer":                     "\U0001F528",
+	"hammer_and_wrench":          "\U0001F6E0️",
+	"heart":                      "❤️",
+	"heavy_check_mark":           "✔️",
+	"heavy_exclamation_mark":     "❗",
+	"heavy_minus_sign":           "➖",
+	"heavy_plus_sign":            "➕",
+	"hourglass":                  "⌛",
+	"hourglass_flowing_sand":     "⏳",
+	"hugging_face":               "\U0001F917",
+	"information_source":         "ℹ️",
+	"joy":                        "\U0001F602",
+	"key":                        "\U0001F511",
+	"large_blue_circle":          "\U0001F535",
+	"large_green_circle":         "\U0001F7E2",
+	"large_yellow_circle":        "\U0001F7E1",
+	"laughing":                   "\U0001F606",
+	"link":                       "\U0001F517",
+	"lock":                       "\U0001F512",
+	"mag":                        "\U0001F50D",
+	"memo":                       "\U0001F4DD",
+	"moneybag":                   "\U0001F4B0",
+	"muscle":                     "\U0001F4AA",
+	"no_entry":                   "⛔",
+	"no_entry_sign":              "\U0001F6AB",
+	"ok":                         "\U0001F197",
+	"ok_hand":                    "\U0001F44C",
+	"package":                    "\U0001F4E6",
+	"partying_face":              "\U0001F973",
+	"pencil2":                    "✏️",
+	"point_down":                 "\U0001F447",
+	"point_left":                 "\U0001F448",
+	"point_right":                "\U0001F449",
+	"point_up":                   "☝️",
+	"pray":                       "\U0001F64F",
+	"pushpin":                    "\U0001F4CC",
+	"question":                   "❓",
+	"raised_hands":               "\U0001F64C",
+	"recycle":                    "♻️",
+	"red_circle":                 "\U0001F534",
+	"robot_face":                 "\U0001F916",
+	"rocket":                     "\U0001F680",
+	"rotating_light":             "\U0001F6A8",
+	"scream":                     "\U0001F631",
+	"see_no_evil":                "\U0001F648",
+	"shrug":                      "\U0001F937",
+	"skull":                      "\U0001F480",
+	"sleeping":                   "\U0001F634",
+	"slightly_smiling_face":      "\U0001F642",
+	"smile":                      "\U0001F604",
+	"smiley":                     "\U0001F603",
+	"smirk":                      "\U0001F60F",
+	"sob":                        "\U0001F62D",
+	"sparkles":                   "✨",
+	"speech_balloon":             "\U0001F4AC",
+	"star":                       "⭐",
+	"stopwatch":                  "⏱️",
+	"sunglasses":                 "\U0001F60E",
+	"sunny":                      "☀️",
+	"sweat_smile":                "\U0001F605",
+	"tada":                       "\U0001F389",
+	"thinking_face":              "\U0001F914",
+	"thumbsdown":                 "\U0001F44E",
+	"thumbsup":                   "\U0001F44D",
+	"trophy":                     "\U0001F3C6",
+	"umbrella":                   "☔",
+	"unlock":                     "\U0001F513",
+	"warning":                    "⚠️",
+	"wave":                       "\U0001F44B",
+	"white_check_mark":           "✅",
+	"wink":                       "\U0001F609",
+	"wrench":                     "\U0001F527",
+	"x":                          "❌",
+	"zap":                        "⚡",
+	"zzz":                        "\U0001F4A4",
+}
@@ -0,0 +1,58 @@
+package slackbot
+
+import "testing"
+
+func TestConvertEmoji(t *testing.T) {
+	for text, want := range map[string]string{
+		":tada: Released!":          "\U0001F389 Released!",
+		":+1: and :-1:":             "\U0001F44D and \U0001F44E",
+		":not_an_emoji: stays":      ":not_an_emoji: stays",
+		"ratio 1:2:3 and a:b":       "ratio 1:2:3 and a:b",
+		"::":                        "::",
+		":tada::tada:":              "\U0001F389\U0001F389",
+		"custom :partyparrot: here": "custom :partyparrot: here",
+	} {
+		if got := ConvertEmoji(text); got != want {
+			t.Errorf("ConvertEmoji(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestEmojiCachedUntilChanged(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("emoji.list", `{"ok":true,"emoji":{"partyparrot":"https://example.com/parrot.gif","parrot":"alias:partyparrot"}}`)
+	for i := 0; i < 2; i++ {
+		emoji, err := bot.Emoji()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emoji["parrot"] != "alias:partyparrot" || len(emoji) != 2 {
+			t.Errorf("got emoji %v", emoji)
+		}
+		emoji["modified"] = "by the caller"
+	}
+	if calls := len(api.callsTo("emoji.list")); calls != 1 {
+		t.Errorf("emoji fetched %d times, want once", calls)
+	}
+	bot.Dispatch([]byte(`{"type":"emoji_changed","subtype":"add","name":"newmoji","value":"https://example.com/new.png"}`))
+	if _, err := bot.Emoji(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(api.callsTo("emoji.list")); calls != 2 {
+		t.Errorf("emoji fetched %d times, want again after a change", calls)
+	}
+}
+
+func TestEmojiErrorsNotCached(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("emoji.list", `{"ok":false,"error":"missing_scope"}`)
+	if _, err := bot.Emoji(); err == nil {
+		t.Fatal("missing_scope not surfaced")
+	} else if apiErr, ok := err.(APIError); !ok || apiErr.Code != "missing_scope" {
+		t.Errorf("got error %v, want missing_scope", err)
+	}
+	api.reply("emoji.list", `{"ok":true,"emoji":{"partyparrot":"https://example.com/parrot.gif"}}`)
+	if emoji, err := bot.Emoji(); err != nil || len(emoji) != 1 {
+		t.Errorf("got emoji %v and error %v after a failure", emoji, err)
+	}
+}
@@ -0,0 +1,85 @@
+package slackbot
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorPolicy determines what happens to errors returned by callbacks when
+// CallbackErrors is not being read, or when its buffer is full.
+type ErrorPolicy int
+
+const (
+	// BlockOnError makes the goroutine handling the event wait until the
+	// error is read from CallbackErrors. This is the default.
+	BlockOnError ErrorPolicy = iota
+	// DropNewestError discards the error if it cannot be delivered right away.
+	DropNewestError
+	// DropOldestError discards the oldest buffered error on CallbackErrors to
+	// make room for the new one. On an unbuffered channel, this behaves like
+	// DropNewestError.
+	DropOldestError
+)
+
+// reportError delivers an error returned by a callback to the client,
+// either through OnCallbackError or on CallbackErrors according to the
+// bot's ErrorPolicy. If CallbackErrors is nil, the error is only logged.
+func (bot *SlackBot) reportError(err error) {
+	if bot.OnCallbackError != nil {
+		bot.OnCallbackError(err)
+		return
+	}
+	// Sending on a nil channel would block forever
+	if bot.CallbackErrors == nil {
+		bot.logger.Println("Dropping callback error:", err)
+		return
+	}
+	switch bot.ErrorPolicy {
+	case DropNewestError:
+		select {
+		case bot.CallbackErrors <- err:
+		default:
+		}
+	case DropOldestError:
+		select {
+		case bot.CallbackErrors <- err:
+			return
+		default:
+		}
+		select {
+		case <-bot.CallbackErrors:
+		default:
+		}
+		select {
+		case bot.CallbackErrors <- err:
+		default:
+		}
+	default:
+		bot.CallbackErrors <- err
+	}
+}
+
+// CallbackTimeoutError is reported when a callback takes longer than the
+// bot's CallbackTimeout. It is reported while the callback is still running.
+type CallbackTimeoutError struct {
+	Event   string        // The type of the event or interaction being handled
+	Timeout time.Duration // The timeout that was exceeded
+}
+
+func (err CallbackTimeoutError) Error() string {
+	return fmt.Sprintf("callback for %s did not return within %v", err.Event, err.Timeout)
+}
+
+// watchCallback reports a CallbackTimeoutError if the callback handling
+// an event or interaction of a given type runs for longer than the bot's
+// CallbackTimeout. The returned function must be called once it returns.
+func (bot *SlackBot) watchCallback(eventType string) (done func()) {
+	timeout := bot.CallbackTimeout
+	if timeout <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(timeout, func() {
+		bot.reportError(CallbackTimeoutError{Event: eventType, Timeout: timeout})
+	})
+	return func() { timer.Stop() }
+}
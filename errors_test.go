@@ -0,0 +1,104 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDropNewestErrorDoesNotLeakGoroutines(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.ErrorPolicy = DropNewestError
+	bot.OnPresenceChange = func(event PresenceChange) error { return errors.New("failed") }
+	before := runtime.NumGoroutine()
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.handleEvent([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+		}()
+	}
+	wg.Wait()
+	eventually(t, func() bool { return runtime.NumGoroutine() <= before+5 },
+		"goroutines accumulated: %d before, %d after", before, runtime.NumGoroutine())
+}
+
+func TestDropOldestErrorKeepsNewest(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.ErrorPolicy = DropOldestError
+	bot.CallbackErrors = make(chan error, 2)
+	for i := 0; i < 5; i++ {
+		bot.reportError(fmt.Errorf("error %d", i))
+	}
+	for _, want := range []string{"error 3", "error 4"} {
+		if err := <-bot.CallbackErrors; err.Error() != want {
+			t.Errorf("got %v, want %s", err, want)
+		}
+	}
+}
+
+func TestOnCallbackErrorReceivesErrors(t *testing.T) {
+	bot := New(newTestLogger())
+	var got []error
+	bot.OnCallbackError = func(err error) { got = append(got, err) }
+	bot.OnPresenceChange = func(event PresenceChange) error { return errors.New("failed") }
+	bot.handleEvent([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	if len(got) != 1 || got[0].Error() != "failed" {
+		t.Errorf("got errors %v", got)
+	}
+}
+
+func TestNilCallbackErrorsDoesNotBlock(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.CallbackErrors = nil
+	bot.OnEvent = func(event Event) error { return errors.New("failed") }
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleEvent([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reporting an error blocked with CallbackErrors set to nil")
+	}
+}
+
+func TestCallbackTimeoutReported(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.CallbackTimeout = 20 * time.Millisecond
+	bot.CallbackErrors = make(chan error, 1)
+	release := make(chan struct{})
+	bot.OnPresenceChange = func(event PresenceChange) error {
+		<-release
+		return nil
+	}
+	go bot.Dispatch([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	select {
+	case err := <-bot.CallbackErrors:
+		timeout, ok := err.(CallbackTimeoutError)
+		if !ok || timeout.Event != "presence_change" || timeout.Timeout != bot.CallbackTimeout {
+			t.Errorf("got error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout not reported while the callback was running")
+	}
+	close(release)
+}
+
+func TestFastCallbackNotReportedAsTimedOut(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.CallbackTimeout = 20 * time.Millisecond
+	bot.CallbackErrors = make(chan error, 1)
+	bot.OnPresenceChange = func(event PresenceChange) error { return nil }
+	bot.Dispatch([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	select {
+	case err := <-bot.CallbackErrors:
+		t.Errorf("got error %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,19 +1,129 @@
 package slackbot
 
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Event is implemented by all events received from the Slack RTM API, allowing
+// them to be inspected generically. The method is called EventType rather than
+// Type since each event also carries its type in a Type field.
+type Event interface {
+	EventType() string // The Slack type of the event, e.g. "message"
+}
+
 type event interface {
+	Event
 	invoke(bot *SlackBot) error // invoke the callback associated to a given event on the bot
 }
 
+// eventFactories holds, for each supported event type, a function creating
+// a new, empty event of that type to unmarshal into.
+var eventFactories = map[string]func() event{
+	"app_mention":          func() event { return &AppMention{} },
+	"dnd_updated_user":     func() event { return &DndUpdatedUser{} },
+	"emoji_changed":        func() event { return &emojiChanged{} },
+	"file_comment_added":   func() event { return &FileCommentAdded{} },
+	"file_comment_deleted": func() event { return &FileCommentDeleted{} },
+	"file_comment_edited":  func() event { return &FileCommentEdited{} },
+	"hello":                func() event { return &Hello{} },
+	"message":              func() event { return &MessageIn{} },
+	"pong":                 func() event { return &pongMessage{} },
+	"presence_change":      func() event { return &PresenceChange{} },
+	"team_join":            func() event { return &TeamJoin{} },
+	"user_change":          func() event { return &UserChange{} },
+	"error":                func() event { return &errorEvent{} },
+}
+
+// isInternal reports whether an event is only of interest to the bot itself,
+// and should not be passed on to the client.
+func isInternal(event event) bool {
+	switch event.(type) {
+	case *pongMessage, *errorEvent, *emojiChanged:
+		return true
+	}
+	return false
+}
+
+// isCallbackOnly reports whether an event stands for an interaction or a
+// slash command rather than a Slack event, and is only passed to its own
+// callbacks, not to OnEvent or WaitFor.
+func isCallbackOnly(event event) bool {
+	switch event.(type) {
+	case *interaction, *slashCommand:
+		return true
+	}
+	return false
+}
+
+// makeEventByType returns a new, empty event of a given type, if the type is
+// supported. Every call returns a distinct instance, so events handled
+// concurrently never share the value they are unmarshalled into.
 func makeEventByType(eventType string) (event, bool) {
-	var eventTypeByEvent = map[string]event{
-		"dnd_updated_user": &DndUpdatedUser{},
-		"hello":            &Hello{},
-		"message":          &MessageIn{},
-		"pong":             &pongMessage{},
-		"presence_change":  &PresenceChange{},
+	factory, exists := eventFactories[eventType]
+	if !exists {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// AppMention represents the event sent when the bot is mentioned in a
+// message. It is only sent to apps subscribed to it through the Events API,
+// e.g. in Socket Mode, and is sent in addition to the message itself.
+// Slack API doc: https://api.slack.com/events/app_mention
+type AppMention struct {
+	Type     string `json:"type"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts"` // Timestamp of the thread's parent message, if in a thread
+	EventTs  string `json:"event_ts"`
+}
+
+// EventType returns "app_mention".
+func (event AppMention) EventType() string { return "app_mention" }
+
+func (event AppMention) invoke(bot *SlackBot) (err error) {
+	if bot.OnAppMention != nil {
+		err = bot.OnAppMention(event)
 	}
-	event, exists := eventTypeByEvent[eventType]
-	return event, exists
+	return
+}
+
+// RegisteredEvents returns, in sorted order, the types of the events and
+// interactions for which the bot currently has a callback. If OnEvent is
+// set, all supported event types are included.
+func (bot *SlackBot) RegisteredEvents() (eventTypes []string) {
+	registered := map[string]bool{
+		"app_mention":          bot.OnAppMention != nil,
+		"dnd_updated_user":     bot.OnDndUpdatedUser != nil,
+		"file_comment_added":   bot.OnFileCommentAdded != nil,
+		"file_comment_deleted": bot.OnFileCommentDeleted != nil,
+		"file_comment_edited":  bot.OnFileCommentEdited != nil,
+		"hello":                bot.OnHello != nil || bot.OnReady != nil,
+		"message": bot.OnMessage != nil || bot.OnMessageReplied != nil || bot.OnThreadBroadcast != nil ||
+			bot.OnMeMessage != nil || bot.OnChannelJoin != nil || bot.OnChannelLeave != nil || bot.OnSlashCommand != nil,
+		"presence_change":     bot.OnPresenceChange != nil,
+		"team_join":           bot.OnTeamJoin != nil,
+		"user_change":         bot.OnUserChange != nil,
+		"block_actions":       bot.OnBlockActions != nil,
+		"interactive_message": bot.OnInteractiveMessage != nil,
+	}
+	for eventType, factory := range eventFactories {
+		if bot.OnEvent != nil && !isInternal(factory()) {
+			registered[eventType] = true
+		}
+	}
+	for eventType, ok := range registered {
+		if ok {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	sort.Strings(eventTypes)
+	return
 }
 
 // DndUpdatedUser represents the event sent when o not Disturb settings change for a team member
@@ -22,12 +132,15 @@ type DndUpdatedUser struct {
 	Type      string `json:"type"`
 	User      string `json:"user"`
 	DndStatus struct {
-		DndEnabled     bool `json:"dnd_enabled"`
-		NextDndStartTs int  `json:"next_dnd_start_ts"`
-		NextDndEndTs   int  `json:"next_dnd_end_ts"`
+		DndEnabled     bool          `json:"dnd_enabled"`
+		NextDndStartTs UnixTimestamp `json:"next_dnd_start_ts"`
+		NextDndEndTs   UnixTimestamp `json:"next_dnd_end_ts"`
 	} `json:"dnd_status"`
 }
 
+// EventType returns "dnd_updated_user".
+func (event DndUpdatedUser) EventType() string { return "dnd_updated_user" }
+
 func (event DndUpdatedUser) invoke(bot *SlackBot) (err error) {
 	if bot.OnDndUpdatedUser != nil {
 		err = bot.OnDndUpdatedUser(event)
@@ -35,13 +148,90 @@ func (event DndUpdatedUser) invoke(bot *SlackBot) (err error) {
 	return
 }
 
+// FileComment represents a comment on a file.
+// Slack API doc: https://api.slack.com/types/file#file_comments
+type FileComment struct {
+	ID        string        `json:"id"`
+	Created   UnixTimestamp `json:"created"`
+	Timestamp UnixTimestamp `json:"timestamp"`
+	User      string        `json:"user"`
+	Comment   string        `json:"comment"`
+}
+
+// FileCommentAdded represents the event sent when a comment is added to a file.
+// Slack API doc: https://api.slack.com/events/file_comment_added
+type FileCommentAdded struct {
+	Type    string      `json:"type"`
+	FileID  string      `json:"file_id"`
+	File    File        `json:"file"` // Often only holds the ID of the file
+	Comment FileComment `json:"comment"`
+}
+
+// EventType returns "file_comment_added".
+func (event FileCommentAdded) EventType() string { return "file_comment_added" }
+
+func (event FileCommentAdded) invoke(bot *SlackBot) (err error) {
+	if bot.OnFileCommentAdded != nil {
+		err = bot.OnFileCommentAdded(event)
+	}
+	return
+}
+
+// FileCommentEdited represents the event sent when a comment on a file is edited.
+// Slack API doc: https://api.slack.com/events/file_comment_edited
+type FileCommentEdited struct {
+	Type    string      `json:"type"`
+	FileID  string      `json:"file_id"`
+	File    File        `json:"file"` // Often only holds the ID of the file
+	Comment FileComment `json:"comment"`
+}
+
+// EventType returns "file_comment_edited".
+func (event FileCommentEdited) EventType() string { return "file_comment_edited" }
+
+func (event FileCommentEdited) invoke(bot *SlackBot) (err error) {
+	if bot.OnFileCommentEdited != nil {
+		err = bot.OnFileCommentEdited(event)
+	}
+	return
+}
+
+// FileCommentDeleted represents the event sent when a comment is deleted from a file.
+// Slack API doc: https://api.slack.com/events/file_comment_deleted
+type FileCommentDeleted struct {
+	Type    string `json:"type"`
+	FileID  string `json:"file_id"`
+	File    File   `json:"file"`    // Often only holds the ID of the file
+	Comment string `json:"comment"` // The ID of the deleted comment
+}
+
+// EventType returns "file_comment_deleted".
+func (event FileCommentDeleted) EventType() string { return "file_comment_deleted" }
+
+func (event FileCommentDeleted) invoke(bot *SlackBot) (err error) {
+	if bot.OnFileCommentDeleted != nil {
+		err = bot.OnFileCommentDeleted(event)
+	}
+	return
+}
+
 // Hello represents the event sent when a connection is opened to the message server.
 // Slack API doc: https://api.slack.com/events/hello
 type Hello struct {
 	Type string `json:"type"`
 }
 
+// EventType returns "hello".
+func (event Hello) EventType() string { return "hello" }
+
 func (event Hello) invoke(bot *SlackBot) (err error) {
+	bot.helloOnce.Do(func() {
+		if bot.OnReady != nil {
+			if err := bot.OnReady(event); err != nil {
+				bot.reportError(err)
+			}
+		}
+	})
 	if bot.OnHello != nil {
 		err = bot.OnHello(event)
 	}
@@ -53,23 +243,136 @@ type pongMessage struct {
 	Type    string `json:"type"`
 }
 
+// EventType returns "pong".
+func (event pongMessage) EventType() string { return "pong" }
+
+// invoke records the pong as a sign of life, provided that it answers a ping
+// that is still outstanding, i.e. one sent but not yet answered. Other pongs,
+// e.g. for pings sent on an earlier connection, are ignored.
 func (event pongMessage) invoke(bot *SlackBot) (err error) {
-	bot.lastPong = event.ReplyTo
+	bot.pingMutex.Lock()
+	defer bot.pingMutex.Unlock()
+	if event.ReplyTo > bot.lastPong && event.ReplyTo <= bot.lastPing {
+		bot.lastPong = event.ReplyTo
+		bot.counters.update(func(counters *counters) { counters.lastPong = time.Now() })
+	} else {
+		bot.logger.Printf("Ignoring pong for unknown ping %d\n", event.ReplyTo)
+	}
 	return nil
 }
 
 // MessageIn represents the event sent when a general message was sent to a channel.
 // Slack API doc: https://api.slack.com/events/message
 type MessageIn struct {
-	Type    string `json:"type"`
-	Hidden  bool   `json:"hidden"`
-	Channel string `json:"channel"`
-	User    string `json:"user"`
-	Text    string `json:"text"`
-	Ts      string `json:"ts"`
+	Type         string `json:"type"`
+	Hidden       bool   `json:"hidden"`
+	Channel      string `json:"channel"`
+	User         string `json:"user"`
+	BotID        string `json:"bot_id"` // The bot that posted the message, if posted by a bot
+	AppID        string `json:"app_id"` // The app that posted the message, if posted by an app
+	Text         string `json:"text"`
+	Ts           string `json:"ts"`
+	ClientMsgID  string `json:"client_msg_id"`  // A UUID assigned to messages posted by users through a client
+	ThreadTs     string `json:"thread_ts"`      // Timestamp of the thread's parent message, if in a thread
+	ParentUserID string `json:"parent_user_id"` // The user who posted the thread's parent message
+	ReplyCount   int    `json:"reply_count"`    // Number of replies, if this is a thread's parent message
+	LatestReply  string `json:"latest_reply"`   // Timestamp of the latest reply, if this is a thread's parent message
+	Subtype      string `json:"subtype"`        // The kind of message, if not a plain message, e.g. "message_replied"
+
+	// Related messages carried by some subtypes. For message_replied, Message
+	// holds the updated parent message of the thread, and for thread_broadcast,
+	// Root holds the parent message of the thread that was replied to.
+	Message *MessageIn `json:"message"`
+	Root    *MessageIn `json:"root"`
+
+	Blocks    []Block    `json:"blocks"`    // The layout of the message, including its rich text for messages posted by users
+	Reactions []Reaction `json:"reactions"` // Reactions on the message, included by some Web API methods such as conversations.history
+
+	// RetryAttempt is, for messages received through Socket Mode, the number
+	// of times Slack sent the message before without it being acknowledged
+	// in time, in which case RetryReason tells why. It is zero otherwise.
+	RetryAttempt int    `json:"-"`
+	RetryReason  string `json:"-"`
+}
+
+// IsThreadReply reports whether the message is a reply in a thread, as
+// opposed to a top-level message or the parent message of a thread.
+func (event MessageIn) IsThreadReply() bool {
+	return event.ThreadTs != "" && event.ThreadTs != event.Ts
+}
+
+// IsMeMessage reports whether the message describes an action of its sender,
+// as posted through "/me waves".
+func (event MessageIn) IsMeMessage() bool {
+	return event.Subtype == "me_message"
+}
+
+// IsDirectMessage reports whether the message was sent in a direct message
+// between a user and the bot.
+func (event MessageIn) IsDirectMessage() bool {
+	return ChannelType(event.Channel) == ChannelTypeIM
+}
+
+// SlashCommand parses the message as an invocation of a slash command, such
+// as "/deploy prod", returning the command, here "/deploy", and the text
+// following it, here "prod". If the message does not start with a command,
+// ok is false. Text such as "/usr/bin" is not considered a command.
+func (event MessageIn) SlashCommand() (command, text string, ok bool) {
+	if !strings.HasPrefix(event.Text, "/") {
+		return
+	}
+	command, text = event.Text, ""
+	if i := strings.IndexAny(command, " \t\n"); i >= 0 {
+		command, text = command[:i], strings.TrimSpace(command[i:])
+	}
+	if len(command) < 2 || strings.IndexFunc(command[1:], func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	}) >= 0 {
+		return "", "", false
+	}
+	return command, text, true
 }
 
+// EventType returns "message".
+func (event MessageIn) EventType() string { return "message" }
+
 func (event MessageIn) invoke(bot *SlackBot) (err error) {
+	if bot.AutoMarkRead && event.Ts != "" && !event.Hidden {
+		defer bot.markRead(event.Channel, event.Ts)
+	}
+	switch event.Subtype {
+	case "message_replied":
+		if bot.OnMessageReplied != nil {
+			err = bot.OnMessageReplied(event)
+		}
+		return
+	case "thread_broadcast":
+		if bot.OnThreadBroadcast != nil {
+			err = bot.OnThreadBroadcast(event)
+			return
+		}
+	case "me_message":
+		if bot.OnMeMessage != nil {
+			err = bot.OnMeMessage(event)
+			return
+		}
+	case "channel_join", "group_join":
+		if bot.OnChannelJoin != nil {
+			err = bot.OnChannelJoin(event)
+			return
+		}
+	case "channel_leave", "group_leave":
+		if bot.OnChannelLeave != nil {
+			err = bot.OnChannelLeave(event)
+			return
+		}
+	}
+	if handler, ok := bot.threadHandler(event); ok && !event.Hidden {
+		return handler(event)
+	}
+	if command, text, ok := event.SlashCommand(); ok && bot.OnSlashCommand != nil && !event.Hidden {
+		return bot.OnSlashCommand(command, text, event)
+	}
 	// The Slack API defines some messages as "Hidden". This includes edits and deletes,
 	// which we will want to ignore here.
 	if bot.OnMessage != nil && !event.Hidden {
@@ -78,6 +381,24 @@ func (event MessageIn) invoke(bot *SlackBot) (err error) {
 	return
 }
 
+// routedBySubtype reports whether a given message is passed to a callback
+// dedicated to its subtype, or, for message_replied, only to such a callback.
+func (bot *SlackBot) routedBySubtype(event MessageIn) bool {
+	switch event.Subtype {
+	case "message_replied":
+		return true
+	case "thread_broadcast":
+		return bot.OnThreadBroadcast != nil
+	case "me_message":
+		return bot.OnMeMessage != nil
+	case "channel_join", "group_join":
+		return bot.OnChannelJoin != nil
+	case "channel_leave", "group_leave":
+		return bot.OnChannelLeave != nil
+	}
+	return false
+}
+
 // PresenceChange represents the event sent when a team member's presence has changed.
 // Slack API doc: https://api.slack.com/events/presence_change
 type PresenceChange struct {
@@ -86,9 +407,49 @@ type PresenceChange struct {
 	Presence string `json:"presence"`
 }
 
+// EventType returns "presence_change".
+func (event PresenceChange) EventType() string { return "presence_change" }
+
 func (event PresenceChange) invoke(bot *SlackBot) (err error) {
 	if bot.OnPresenceChange != nil {
 		err = bot.OnPresenceChange(event)
 	}
 	return
 }
+
+// TeamJoin represents the event sent when a new member joins the team.
+// Slack API doc: https://api.slack.com/events/team_join
+type TeamJoin struct {
+	Type string `json:"type"`
+	User User   `json:"user"`
+}
+
+// EventType returns "team_join".
+func (event TeamJoin) EventType() string { return "team_join" }
+
+func (event TeamJoin) invoke(bot *SlackBot) (err error) {
+	bot.cacheUser(event.User)
+	if bot.OnTeamJoin != nil {
+		err = bot.OnTeamJoin(event)
+	}
+	return
+}
+
+// UserChange represents the event sent when a team member's data, such as
+// their profile, changes.
+// Slack API doc: https://api.slack.com/events/user_change
+type UserChange struct {
+	Type string `json:"type"`
+	User User   `json:"user"`
+}
+
+// EventType returns "user_change".
+func (event UserChange) EventType() string { return "user_change" }
+
+func (event UserChange) invoke(bot *SlackBot) (err error) {
+	bot.cacheUser(event.User)
+	if bot.OnUserChange != nil {
+		err = bot.OnUserChange(event)
+	}
+	return
+}
@@ -0,0 +1,470 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventTypes(t *testing.T) {
+	for eventType, factory := range eventFactories {
+		if got := factory().EventType(); got != eventType {
+			t.Errorf("event created for %s reports type %s", eventType, got)
+		}
+	}
+}
+
+func TestOnEventReceivesTypedEvents(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []Event
+	bot.OnEvent = func(event Event) error {
+		received = append(received, event)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"hello"}`))
+	bot.handleEvent([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	bot.handleEvent([]byte(`{"type":"pong","reply_to":1}`))
+	if len(received) != 2 {
+		t.Fatalf("got %d events, want 2 excluding the pong", len(received))
+	}
+	if _, ok := received[0].(Hello); !ok || received[0].EventType() != "hello" {
+		t.Errorf("got %#v, want a Hello", received[0])
+	}
+	if presence, ok := received[1].(PresenceChange); !ok || presence.User != "U1" || presence.EventType() != "presence_change" {
+		t.Errorf("got %#v, want a PresenceChange for U1", received[1])
+	}
+}
+
+func TestThreadedMessages(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []MessageIn
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U2","text":"reply","ts":"2.0",
+		"thread_ts":"1.0","parent_user_id":"U1"}`))
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"parent","ts":"1.0",
+		"thread_ts":"1.0","reply_count":3}`))
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"plain","ts":"3.0"}`))
+	if len(received) != 3 {
+		t.Fatalf("got %d messages, want 3", len(received))
+	}
+	reply, parent, plain := received[0], received[1], received[2]
+	if !reply.IsThreadReply() || reply.ThreadTs != "1.0" || reply.ParentUserID != "U1" {
+		t.Errorf("reply parsed as %+v", reply)
+	}
+	if parent.IsThreadReply() || parent.ReplyCount != 3 {
+		t.Errorf("parent parsed as %+v", parent)
+	}
+	if plain.IsThreadReply() || plain.ThreadTs != "" {
+		t.Errorf("plain message parsed as %+v", plain)
+	}
+}
+
+func TestMessageRepliedRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var replied []MessageIn
+	bot.OnMessageReplied = func(event MessageIn) error {
+		replied = append(replied, event)
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		t.Errorf("OnMessage called for %+v", event)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"message","subtype":"message_replied","hidden":true,"channel":"C1","ts":"1.1",
+		"message":{"type":"message","user":"U1","text":"parent","thread_ts":"1.0","reply_count":1,
+			"replies":[{"user":"U2","ts":"2.0"}],"ts":"1.0"}}`))
+	if len(replied) != 1 {
+		t.Fatalf("OnMessageReplied called %d times, want 1", len(replied))
+	}
+	if parent := replied[0].Message; parent == nil || parent.Text != "parent" || parent.ReplyCount != 1 {
+		t.Errorf("parent message parsed as %+v", parent)
+	}
+}
+
+func TestThreadBroadcastRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var broadcasts []MessageIn
+	bot.OnThreadBroadcast = func(event MessageIn) error {
+		broadcasts = append(broadcasts, event)
+		return nil
+	}
+	payload := []byte(`{"type":"message","subtype":"thread_broadcast","channel":"C1","user":"U2","text":"also here",
+		"ts":"2.0","thread_ts":"1.0","root":{"type":"message","user":"U1","text":"parent","ts":"1.0","thread_ts":"1.0"}}`)
+	bot.handleEvent(payload)
+	if len(broadcasts) != 1 {
+		t.Fatalf("OnThreadBroadcast called %d times, want 1", len(broadcasts))
+	}
+	if reply := broadcasts[0]; reply.Text != "also here" || reply.Root == nil || reply.Root.Text != "parent" {
+		t.Errorf("broadcast parsed as %+v", reply)
+	}
+	// Without a dedicated callback, broadcasts are ordinary messages
+	bot.OnThreadBroadcast = nil
+	var messages []MessageIn
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event)
+		return nil
+	}
+	bot.handleEvent(payload)
+	if len(messages) != 1 {
+		t.Errorf("OnMessage called %d times, want 1", len(messages))
+	}
+}
+
+func TestSlashCommandRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var commands, texts, messages []string
+	bot.OnSlashCommand = func(command, text string, message MessageIn) error {
+		commands = append(commands, command)
+		texts = append(texts, text)
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event.Text)
+		return nil
+	}
+	for _, text := range []string{"/deploy prod", "deploy prod", "/usr/bin is a path", "/"} {
+		bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"` + text + `"}`))
+	}
+	if len(commands) != 1 || commands[0] != "/deploy" || texts[0] != "prod" {
+		t.Errorf("OnSlashCommand called with %v and %v", commands, texts)
+	}
+	if len(messages) != 3 {
+		t.Errorf("OnMessage called with %v", messages)
+	}
+}
+
+func TestConcurrentDispatchUsesFreshInstances(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var mutex sync.Mutex
+	blocks := make(map[*Block]bool)
+	bot.OnMessage = func(event MessageIn) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(event.Blocks) == 1 {
+			blocks[&event.Blocks[0]] = true
+		}
+		return nil
+	}
+	// Unmarshalling into a shared instance would reuse its blocks
+	rawEvent := []byte(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0",
+		"blocks":[{"type":"rich_text","block_id":"b1","elements":[]}]}`)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			bot.handleEvent(rawEvent)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if len(blocks) != 20 {
+		t.Errorf("20 dispatches gave %d distinct events", len(blocks))
+	}
+}
+
+func TestConcurrentDispatchDoesNotShareEvents(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var mutex sync.Mutex
+	seen := make(map[string]int)
+	bot.OnPresenceChange = func(event PresenceChange) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		seen[event.User+"/"+event.Presence]++
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			presence := "away"
+			if i%2 == 0 {
+				presence = "active"
+			}
+			bot.handleEvent([]byte(fmt.Sprintf(`{"type":"presence_change","user":"U%d","presence":"%s"}`, i, presence)))
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 100; i++ {
+		presence := "away"
+		if i%2 == 0 {
+			presence = "active"
+		}
+		if key := fmt.Sprintf("U%d/%s", i, presence); seen[key] != 1 {
+			t.Errorf("event %s seen %d times", key, seen[key])
+		}
+	}
+}
+
+func BenchmarkMakeEventByType(b *testing.B) {
+	b.Run("MapPerEvent", func(b *testing.B) {
+		// The map of instances built for every event before the factories
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			eventTypeByEvent := map[string]event{
+				"dnd_updated_user": &DndUpdatedUser{},
+				"hello":            &Hello{},
+				"message":          &MessageIn{},
+				"pong":             &pongMessage{},
+				"presence_change":  &PresenceChange{},
+			}
+			_ = eventTypeByEvent["message"]
+		}
+	})
+	b.Run("Factories", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			makeEventByType("message")
+		}
+	})
+}
+
+func TestOnEventAndSpecificCallbackBothFire(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var order []string
+	bot.OnEvent = func(event Event) error {
+		order = append(order, "OnEvent "+event.EventType())
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		order = append(order, "OnMessage "+event.Text)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hi"}`))
+	if len(order) != 2 || order[0] != "OnEvent message" || order[1] != "OnMessage hi" {
+		t.Errorf("callbacks called as %v", order)
+	}
+}
+
+func TestMeMessageRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var actions, messages []MessageIn
+	bot.OnMeMessage = func(event MessageIn) error {
+		actions = append(actions, event)
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event)
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"message","subtype":"me_message","channel":"C1","user":"U1","text":"waves","ts":"1.0"}`))
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"2.0"}`))
+	if len(actions) != 1 || actions[0].Text != "waves" || !actions[0].IsMeMessage() {
+		t.Errorf("OnMeMessage called with %+v", actions)
+	}
+	if len(messages) != 1 || messages[0].IsMeMessage() {
+		t.Errorf("OnMessage called with %+v", messages)
+	}
+}
+
+func TestBotAndAppIDs(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []MessageIn
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","subtype":"bot_message","channel":"C1","text":"beep",
+		"bot_id":"B1","app_id":"A1","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"2.0"}`))
+	if len(received) != 2 {
+		t.Fatalf("got %d messages, want 2", len(received))
+	}
+	if received[0].BotID != "B1" || received[0].AppID != "A1" {
+		t.Errorf("bot message parsed as %+v", received[0])
+	}
+	if received[1].BotID != "" || received[1].AppID != "" {
+		t.Errorf("human message parsed as %+v", received[1])
+	}
+}
+
+func TestClientMsgID(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []MessageIn
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"1.0",
+		"client_msg_id":"4c2a3f1e-7d0b-4e8a-9f6c-2b1d5e8a7c3f"}`))
+	bot.Dispatch([]byte(`{"type":"message","subtype":"bot_message","channel":"C1","text":"beep","bot_id":"B1","ts":"2.0"}`))
+	if len(received) != 2 {
+		t.Fatalf("got %d messages, want 2", len(received))
+	}
+	if received[0].ClientMsgID != "4c2a3f1e-7d0b-4e8a-9f6c-2b1d5e8a7c3f" {
+		t.Errorf("client_msg_id parsed as %q", received[0].ClientMsgID)
+	}
+	if received[1].ClientMsgID != "" {
+		t.Errorf("client_msg_id %q on a message without one", received[1].ClientMsgID)
+	}
+}
+
+func TestChannelJoinLeaveRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var joins, leaves, messages []MessageIn
+	bot.OnChannelJoin = func(event MessageIn) error {
+		joins = append(joins, event)
+		return nil
+	}
+	bot.OnChannelLeave = func(event MessageIn) error {
+		leaves = append(leaves, event)
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","subtype":"channel_join","channel":"C1","user":"U1",
+		"text":"<@U1> has joined the channel","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","subtype":"channel_leave","channel":"C1","user":"U2",
+		"text":"<@U2> has left the channel","ts":"2.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U3","text":"hello","ts":"3.0"}`))
+	if len(joins) != 1 || joins[0].User != "U1" || joins[0].Channel != "C1" {
+		t.Errorf("OnChannelJoin got %+v", joins)
+	}
+	if len(leaves) != 1 || leaves[0].User != "U2" || leaves[0].Channel != "C1" {
+		t.Errorf("OnChannelLeave got %+v", leaves)
+	}
+	if len(messages) != 1 || messages[0].Text != "hello" {
+		t.Errorf("OnMessage got %+v", messages)
+	}
+}
+
+func TestAppMentionRouting(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var mentions []AppMention
+	bot.OnAppMention = func(event AppMention) error {
+		mentions = append(mentions, event)
+		return nil
+	}
+	var messages int
+	bot.OnMessage = func(event MessageIn) error {
+		messages++
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"app_mention","channel":"C1","user":"U1","text":"<@UBOT> deploy prod",
+		"ts":"2.0","thread_ts":"1.0","event_ts":"2.0"}`))
+	want := AppMention{Type: "app_mention", Channel: "C1", User: "U1", Text: "<@UBOT> deploy prod", Ts: "2.0", ThreadTs: "1.0", EventTs: "2.0"}
+	if len(mentions) != 1 || mentions[0] != want {
+		t.Errorf("OnAppMention got %+v", mentions)
+	}
+	if messages != 0 {
+		t.Errorf("mention passed to OnMessage")
+	}
+}
+
+func TestRegisteredEvents(t *testing.T) {
+	bot := New(newTestLogger())
+	if registered := bot.RegisteredEvents(); len(registered) != 0 {
+		t.Errorf("registered events %v without callbacks", registered)
+	}
+	bot.OnPresenceChange = func(event PresenceChange) error { return nil }
+	bot.OnThreadBroadcast = func(event MessageIn) error { return nil }
+	bot.OnBlockActions = func(interaction InteractionCallback) error { return nil }
+	registered := bot.RegisteredEvents()
+	if want := []string{"block_actions", "message", "presence_change"}; !reflect.DeepEqual(registered, want) {
+		t.Errorf("registered events %v, want %v", registered, want)
+	}
+	bot.OnPresenceChange = nil
+	bot.OnEvent = func(event Event) error { return nil }
+	registered = bot.RegisteredEvents()
+	for _, eventType := range []string{"app_mention", "presence_change", "team_join", "block_actions"} {
+		if i := sort.SearchStrings(registered, eventType); i == len(registered) || registered[i] != eventType {
+			t.Errorf("%s not registered with OnEvent set", eventType)
+		}
+	}
+}
+
+func TestFileCommentEvents(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var added FileCommentAdded
+	var edited FileCommentEdited
+	var deleted FileCommentDeleted
+	bot.OnFileCommentAdded = func(event FileCommentAdded) error {
+		added = event
+		return nil
+	}
+	bot.OnFileCommentEdited = func(event FileCommentEdited) error {
+		edited = event
+		return nil
+	}
+	bot.OnFileCommentDeleted = func(event FileCommentDeleted) error {
+		deleted = event
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"file_comment_added","file_id":"F1","file":{"id":"F1"},
+		"comment":{"id":"Fc1","created":1356032811,"timestamp":1356032811,"user":"U1","comment":"Nice picture"}}`))
+	bot.Dispatch([]byte(`{"type":"file_comment_edited","file_id":"F1","file":{"id":"F1"},
+		"comment":{"id":"Fc1","created":1356032811,"timestamp":1356032900,"user":"U1","comment":"Very nice picture"}}`))
+	bot.Dispatch([]byte(`{"type":"file_comment_deleted","file_id":"F1","file":{"id":"F1"},"comment":"Fc1"}`))
+	wantAdded := FileComment{ID: "Fc1", Created: 1356032811, Timestamp: 1356032811, User: "U1", Comment: "Nice picture"}
+	if added.FileID != "F1" || added.File.ID != "F1" || added.Comment != wantAdded {
+		t.Errorf("file_comment_added parsed as %+v", added)
+	}
+	if edited.FileID != "F1" || edited.Comment.Comment != "Very nice picture" || edited.Comment.Timestamp != 1356032900 {
+		t.Errorf("file_comment_edited parsed as %+v", edited)
+	}
+	if deleted.FileID != "F1" || deleted.File.ID != "F1" || deleted.Comment != "Fc1" {
+		t.Errorf("file_comment_deleted parsed as %+v", deleted)
+	}
+}
+
+func TestDroppedMessagesReachNoCallbacks(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.SetIdentity("UBOT", "bot")
+	bot.DedupWindow = 10
+	bot.IgnoreOwnMessages = true
+	bot.BackfillChannels = []string{"C2"}
+	var events, messages []string
+	bot.OnEvent = func(event Event) error {
+		if message, ok := event.(MessageIn); ok {
+			events = append(events, message.Text)
+		}
+		return nil
+	}
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event.Text)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"once","ts":"1.0","client_msg_id":"m1"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C2","user":"U1","text":"before gap","ts":"1.0"}`))
+	api.reply("conversations.history", `{"ok":true,"messages":[{"type":"message","user":"U1","text":"missed","ts":"3.0"}]}`)
+	bot.backfill()
+	waited := make(chan Event, 1)
+	go func() {
+		event, _ := bot.WaitFor(context.Background(), func(event Event) bool { return true })
+		waited <- event
+	}()
+	eventually(t, func() bool { return waiting(bot) == 1 }, "WaitFor not waiting")
+	// A duplicate, a message of the bot's own, and a message already backfilled
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"once","ts":"1.0","client_msg_id":"m1"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"UBOT","text":"own","ts":"2.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C2","user":"U1","text":"missed","ts":"3.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"last","ts":"4.0"}`))
+	want := "once,before gap,missed,last"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("OnEvent got %s, want %s", got, want)
+	}
+	if got := strings.Join(messages, ","); got != want {
+		t.Errorf("OnMessage got %s, want %s", got, want)
+	}
+	select {
+	case event := <-waited:
+		if message, ok := event.(MessageIn); !ok || message.Text != "last" {
+			t.Errorf("WaitFor got %+v, want the last message", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("WaitFor did not return")
+	}
+}
@@ -0,0 +1,133 @@
+package slackbot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Helpers for building the special tokens that Slack uses in message text.
+// Slack API doc: https://api.slack.com/reference/surfaces/formatting
+
+// escaper escapes the characters that Slack treats as control characters.
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Mention returns a token mentioning the user with a given ID, e.g. <@U123>.
+func Mention(userID string) string {
+	return "<@" + userID + ">"
+}
+
+// ChannelLink returns a token linking to the channel with a given ID, e.g.
+// <#C123|general>. The name is optional and may be left empty.
+func ChannelLink(id, name string) string {
+	if name == "" {
+		return "<#" + id + ">"
+	}
+	return "<#" + id + "|" + escaper.Replace(name) + ">"
+}
+
+// Link returns a token linking to a given URL, displayed with a given label,
+// e.g. <http://example.com|Example>. The label is optional and may be left empty.
+func Link(url, label string) string {
+	if label == "" {
+		return "<" + url + ">"
+	}
+	return "<" + url + "|" + escaper.Replace(label) + ">"
+}
+
+// DateToken returns a token displaying a given time in the reader's own time
+// zone, formatted according to a Slack date format string such as
+// "{date_short} at {time}". Clients unable to render the token show the time
+// in UTC instead, e.g. <!date^1392734382^{date_short} at {time}|Tue, 18 Feb 2014 14:39:42 UTC>.
+func DateToken(t time.Time, format string) string {
+	fallback := t.UTC().Format(time.RFC1123)
+	return "<!date^" + strconv.FormatInt(t.Unix(), 10) + "^" + format + "|" + escaper.Replace(fallback) + ">"
+}
+
+// mentionPattern matches user, channel, and special mention tokens, capturing
+// the kind of token, the ID, and the label, if any.
+var mentionPattern = regexp.MustCompile(`<([@#!])([^|>]+)(?:\|([^>]*))?>`)
+
+// ResolveMentions replaces the user, channel, and special mention tokens in a
+// given message text by readable names, e.g. turning "<@U123> see <#C456>" into
+// "@alice see #general", for logging or displaying the text elsewhere. Users and
+// channels are looked up through UserInfo and ChannelInfo, so unknown ones are
+// fetched from Slack. Special mentions such as <!here> become "@here". Links
+// are left unchanged.
+func (bot *SlackBot) ResolveMentions(text string) (resolved string, err error) {
+	resolved = mentionPattern.ReplaceAllStringFunc(text, func(token string) string {
+		if err != nil {
+			return token
+		}
+		parts := mentionPattern.FindStringSubmatch(token)
+		kind, id, label := parts[1], parts[2], parts[3]
+		switch kind {
+		case "@":
+			var user User
+			if user, err = bot.UserInfo(id); err != nil {
+				return token
+			}
+			if user.Profile.DisplayName != "" {
+				return "@" + user.Profile.DisplayName
+			}
+			return "@" + user.Name
+		case "#":
+			if label != "" {
+				return "#" + label
+			}
+			var channel Channel
+			if channel, err = bot.ChannelInfo(id); err != nil {
+				return token
+			}
+			return "#" + channel.Name
+		}
+		// Special mentions, such as <!here>, and others such as user groups
+		// and dates, which are replaced by their label if they have one
+		switch id {
+		case "here", "channel", "everyone":
+			return "@" + id
+		}
+		if label != "" {
+			return label
+		}
+		return token
+	})
+	return
+}
+
+// FormattingError is returned when sending a message with a malformed
+// token if ValidateFormatting is set. Such tokens are shown as literal text.
+type FormattingError struct {
+	Text   string // The text of the message
+	Offset int    // The byte offset in Text of the malformed token
+}
+
+func (err FormattingError) Error() string {
+	return fmt.Sprintf("malformed token at offset %d in message %q", err.Offset, err.Text)
+}
+
+// ValidateFormatting checks that the special tokens in a given message text
+// are well-formed: every "<" must be closed by a ">" before the next "<",
+// and tokens must not be empty, also after a prefix such as "@" or "#".
+// Literal angle brackets must be escaped, e.g. through Link or ChannelLink.
+func ValidateFormatting(text string) error {
+	for offset := 0; offset < len(text); {
+		start := strings.IndexByte(text[offset:], '<')
+		if start < 0 {
+			return nil
+		}
+		start += offset
+		end := strings.IndexAny(text[start+1:], "<>")
+		if end < 0 || text[start+1+end] == '<' {
+			return FormattingError{Text: text, Offset: start}
+		}
+		token := strings.TrimLeft(text[start+1:start+1+end], "@#!")
+		if token == "" || token[0] == '|' {
+			return FormattingError{Text: text, Offset: start}
+		}
+		offset = start + 1 + end + 1
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormattingTokens(t *testing.T) {
+	date := time.Date(2014, 2, 18, 14, 39, 42, 0, time.FixedZone("CET", 3600))
+	for _, test := range []struct{ got, want string }{
+		{Mention("U123"), "<@U123>"},
+		{ChannelLink("C123", "general"), "<#C123|general>"},
+		{ChannelLink("C123", ""), "<#C123>"},
+		{Link("http://example.com", "Example"), "<http://example.com|Example>"},
+		{Link("http://example.com", ""), "<http://example.com>"},
+		{Link("http://example.com", "a < b & c"), "<http://example.com|a &lt; b &amp; c>"},
+		{DateToken(date, "{date_short} at {time}"), "<!date^1392730782^{date_short} at {time}|Tue, 18 Feb 2014 13:39:42 UTC>"},
+	} {
+		if test.got != test.want {
+			t.Errorf("got %s, want %s", test.got, test.want)
+		}
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("users.info", func(call apiCall) interface{} {
+		switch call.Form.Get("user") {
+		case "U1":
+			return `{"ok":true,"user":{"id":"U1","name":"alice","profile":{"display_name":"ali"}}}`
+		case "U2":
+			return `{"ok":true,"user":{"id":"U2","name":"bob","profile":{"display_name":""}}}`
+		}
+		return `{"ok":false,"error":"user_not_found"}`
+	})
+	api.reply("conversations.info", `{"ok":true,"channel":{"id":"C1","name":"general"}}`)
+	resolved, err := bot.ResolveMentions("<@U1> <@U2> <@U1|alice> see <#C1> and <#C2|random>, <!here> <!channel> <!subteam^S1|@devs> <https://example.com|link>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "@ali @bob @ali see #general and #random, @here @channel @devs <https://example.com|link>"; resolved != want {
+		t.Errorf("resolved as %q, want %q", resolved, want)
+	}
+	if calls := len(api.callsTo("users.info")); calls != 2 {
+		t.Errorf("looked up users %d times, want each once", calls)
+	}
+	if _, err := bot.ResolveMentions("hi <@U3>"); err == nil {
+		t.Error("unknown user gave no error")
+	}
+}
+
+func TestValidateFormatting(t *testing.T) {
+	for _, text := range []string{
+		"plain text",
+		"hi <@U123>, see <#C1|general> and <https://example.com|the docs> <!here>",
+		"escaped &lt;brackets&gt;",
+	} {
+		if err := ValidateFormatting(text); err != nil {
+			t.Errorf("%q flagged: %v", text, err)
+		}
+	}
+	for _, test := range []struct {
+		text   string
+		offset int
+	}{
+		{"hi <@U123", 3},
+		{"<@U1> and <@U2 <#C1>", 10},
+		{"empty <@> mention", 6},
+		{"<|label>", 0},
+	} {
+		err, ok := ValidateFormatting(test.text).(FormattingError)
+		if !ok || err.Offset != test.offset {
+			t.Errorf("%q gave error %v, want one at offset %d", test.text, err, test.offset)
+		}
+	}
+}
+
+func TestValidateFormattingWhenSending(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	// Validation is opt-in
+	if err := bot.SendMessage("C1", "hi <@U123"); err != nil {
+		t.Fatal(err)
+	}
+	c.next(t)
+	bot.ValidateFormatting = true
+	if _, ok := bot.SendMessage("C1", "hi <@U123").(FormattingError); !ok {
+		t.Error("malformed message not flagged")
+	}
+	if err := bot.SendMessage("C1", "hi <@U123>"); err != nil {
+		t.Fatal(err)
+	}
+	if message := c.next(t); message["text"] != "hi <@U123>" {
+		t.Errorf("sent %v, want only the well-formed message", message)
+	}
+}
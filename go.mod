@@ -0,0 +1,5 @@
+module github.com/fuglede/slackbot
+
+go 1.18
+
+require golang.org/x/net v0.35.0
@@ -1,29 +1,195 @@
 package slackbot
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"sync/atomic"
-
-	"golang.org/x/net/websocket"
+	"time"
+	"unicode/utf8"
 )
 
 // SendMessage sends a given message to a given channel.
-func (bot SlackBot) SendMessage(channel string, message string) error {
-	atomic.AddInt32(&bot.messageID, 1)
+func (bot *SlackBot) SendMessage(channel string, message string) error {
+	return bot.SendMessageContext(context.Background(), channel, message)
+}
+
+// SendMessageContext sends a given message to a given channel, giving up
+// if the context is cancelled or its deadline passes before the message
+// has been sent, including while waiting to respect SendRate. A message given
+// up on may still reach Slack, as one already being written when the context
+// is done is still written once the connection allows it. Messages longer
+// than MaxMessageLength are sent in several parts.
+func (bot *SlackBot) SendMessageContext(ctx context.Context, channel string, message string) error {
+	if bot.ValidateFormatting {
+		if err := ValidateFormatting(message); err != nil {
+			return err
+		}
+	}
+	for _, part := range splitMessage(message, bot.MaxMessageLength) {
+		if err := bot.sendMessagePart(ctx, channel, part)(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendAsync sends a given message to a given channel without waiting for it
+// to be sent, reporting any error like a callback error instead. Messages
+// are still sent in order. SendAsync only blocks if the queue of messages to
+// the channel is full, or while waiting for the connection per ReadyTimeout.
+func (bot *SlackBot) SendAsync(channel string, message string) {
+	if bot.ValidateFormatting {
+		if err := ValidateFormatting(message); err != nil {
+			bot.reportError(err)
+			return
+		}
+	}
+	var waits []func() error
+	for _, part := range splitMessage(message, bot.MaxMessageLength) {
+		waits = append(waits, bot.sendMessagePart(context.Background(), channel, part))
+	}
+	go func() {
+		for _, wait := range waits {
+			if err := wait(); err != nil {
+				bot.reportError(err)
+			}
+		}
+	}()
+}
+
+// splitMessage splits a message into parts of at most max characters each,
+// breaking at line boundaries where possible and otherwise at word
+// boundaries. Joining the parts gives back the original message. If max is
+// not positive, the message is not split.
+func splitMessage(message string, max int) (parts []string) {
+	for max > 0 && utf8.RuneCountInString(message) > max {
+		// Find the byte offset of the first character not fitting
+		cut, count := 0, 0
+		for cut = range message {
+			if count == max {
+				break
+			}
+			count++
+		}
+		if i := strings.LastIndex(message[:cut], "\n"); i > 0 {
+			cut = i + 1
+		} else if i := strings.LastIndexAny(message[:cut], " \t"); i > 0 {
+			cut = i + 1
+		}
+		parts = append(parts, message[:cut])
+		message = message[cut:]
+	}
+	return append(parts, message)
+}
+
+// sendMessagePart starts sending a single message of acceptable length, and
+// returns a function waiting for the result.
+func (bot *SlackBot) sendMessagePart(ctx context.Context, channel string, message string) (wait func() error) {
+	done := func(err error) func() error { return func() error { return err } }
+	if err := ctx.Err(); err != nil {
+		return done(err)
+	}
+	if bot.DryRun {
+		bot.recordDryRun(channel, message)
+		return done(nil)
+	}
+	if err := bot.waitReady(ctx); err != nil {
+		return done(err)
+	}
+	if bot.SocketMode {
+		// Socket Mode connections cannot be used for sending messages
+		return func() error {
+			_, err := bot.PostMessage(PostMessageParameters{Channel: channel, Text: message})
+			return err
+		}
+	}
+	id := atomic.AddInt32(&bot.messageID, 1)
 	bot.logger.Printf("Sending message %s to channel %s\n", message, channel)
 	messageOut := &messageOut{
-		ID:      bot.messageID,
-		Type:    "message",
-		Channel: channel,
-		Text:    message,
+		ID:          id,
+		Type:        "message",
+		Channel:     channel,
+		Text:        message,
+		ClientMsgID: newClientMsgID(),
+	}
+	// The message is considered sent once Slack replies to it. The reply
+	// cannot be waited for while listen is blocked calling callbacks.
+	waitAck := bot.SendAckTimeout > 0 && atomic.LoadInt32(&bot.dispatching) == 0
+	acked := bot.addPending(id, messageOut, waitAck)
+	request := &sendRequest{ctx: ctx, channel: channel, message: messageOut, sent: make(chan error, 1)}
+	bot.enqueue(request)
+	return func() error {
+		select {
+		case err := <-request.sent:
+			if err != nil {
+				bot.counters.update(func(counters *counters) { counters.sendErrors++ })
+				return err
+			}
+		case <-ctx.Done():
+			// If the message is still queued, the queue forgets it
+			bot.stopWaiting(id, acked)
+			return ctx.Err()
+		}
+		if !waitAck {
+			return nil
+		}
+		timer := time.NewTimer(bot.SendAckTimeout)
+		defer timer.Stop()
+		select {
+		case err := <-acked:
+			return err
+		case <-timer.C:
+			if err, replied := bot.stopWaiting(id, acked); replied {
+				return err
+			}
+			if bot.FailOnAckTimeout {
+				return ErrAckTimeout
+			}
+			return nil
+		case <-ctx.Done():
+			if err, replied := bot.stopWaiting(id, acked); replied {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// messageSent calls OnMessageSent, if set, for a message that was sent, and
+// echoes the message back to the bot if EchoSentMessages is set.
+func (bot *SlackBot) messageSent(channel, text, ts string) {
+	bot.counters.update(func(counters *counters) { counters.sent++ })
+	if bot.OnMessageSent != nil {
+		bot.OnMessageSent(channel, text, ts)
+	}
+	if bot.EchoSentMessages {
+		echo, _ := json.Marshal(MessageIn{Type: "message", Channel: channel, User: bot.selfID(), Text: text, Ts: ts})
+		if bot.DispatchSync {
+			bot.handleEvent(echo)
+		} else {
+			go bot.handleEvent(echo)
+		}
 	}
-	return websocket.JSON.Send(bot.ws, messageOut)
 }
 
 // messageOut represents an outbound message
 // Slack API doc: https://api.slack.com/rtm
 type messageOut struct {
-	ID      int32  `json:"id"`
-	Type    string `json:"type"`
-	Channel string `json:"channel"`
-	Text    string `json:"text"`
+	ID          int32  `json:"id"`
+	Type        string `json:"type"`
+	Channel     string `json:"channel"`
+	Text        string `json:"text,omitempty"`
+	ClientMsgID string `json:"client_msg_id,omitempty"` // Identifies the message if it is ever resent
+}
+
+// newClientMsgID returns a random UUID for identifying a sent message.
+func newClientMsgID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
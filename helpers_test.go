@@ -0,0 +1,235 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSendMessageBeforeStart(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.SendAckTimeout = time.Second
+	if err := bot.SendMessage("C1", "too early"); err == nil {
+		t.Error("sending before Start succeeded")
+	}
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if len(bot.pending) != 0 {
+		t.Errorf("%d messages left awaiting acknowledgement", len(bot.pending))
+	}
+}
+
+func TestSendMessageContextCancelledDuringRateLimit(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendRate = 0.1
+	if err := bot.SendMessage("C1", "first"); err != nil {
+		t.Fatal(err)
+	}
+	c.next(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bot.SendMessageContext(ctx, "C1", "second"); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, long after the deadline", elapsed)
+	}
+}
+
+func TestSendMessageContextAlreadyCancelled(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bot.SendMessageContext(ctx, "C1", "too late"); err != context.Canceled {
+		t.Errorf("got error %v, want cancelled", err)
+	}
+	select {
+	case message := <-c.sent:
+		t.Errorf("sent %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+	bot.pendingMutex.Lock()
+	defer bot.pendingMutex.Unlock()
+	if len(bot.pending) != 0 {
+		t.Errorf("%d messages left awaiting acknowledgement", len(bot.pending))
+	}
+}
+
+func TestSendMessageContextCancelledDuringWrite(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	c.gate = make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bot.SendMessageContext(ctx, "C1", "blocked"); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, long after the deadline", elapsed)
+	}
+	// The write already under way is completed once the connection allows
+	c.gate <- struct{}{}
+	if sent := c.next(t); sent["text"] != "blocked" {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestSendMessageContextCancelledDuringAckWait(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendAckTimeout = 10 * time.Second
+	bot.FailOnAckTimeout = true
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bot.SendMessageContext(ctx, "C1", "unanswered"); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, long after the deadline", elapsed)
+	}
+	if sent := c.next(t); sent["text"] != "unanswered" {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestSendMessageContextWaitsForAck(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendAckTimeout = 10 * time.Second
+	go bot.listen()
+	go func() {
+		var sent messageOut
+		json.Unmarshal(<-c.sent, &sent)
+		c.push(fmt.Sprintf(`{"ok":true,"reply_to":%d,"ts":"1.5","text":"acked"}`, sent.ID))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bot.SendMessageContext(ctx, "C1", "acked"); err != nil {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestOnMessageSent(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	type sentMessage struct{ channel, text, ts string }
+	var sent []sentMessage
+	bot.OnMessageSent = func(channel, text, ts string) {
+		sent = append(sent, sentMessage{channel, text, ts})
+	}
+	bot.OnCallbackError = func(err error) {}
+	for _, text := range []string{"hello", "rejected"} {
+		if err := bot.SendMessage("C1", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := c.next(t), c.next(t)
+	if len(sent) != 0 {
+		t.Errorf("OnMessageSent called before Slack replied: %+v", sent)
+	}
+	bot.handleEvent([]byte(fmt.Sprintf(`{"ok":true,"reply_to":%v,"ts":"1.5"}`, first["id"])))
+	bot.handleEvent([]byte(fmt.Sprintf(`{"ok":false,"reply_to":%v,"error":{"code":2,"msg":"message text is missing"}}`, second["id"])))
+	if len(sent) != 1 || sent[0] != (sentMessage{"C1", "hello", "1.5"}) {
+		t.Errorf("OnMessageSent called with %+v", sent)
+	}
+}
+
+func TestOnMessageSentWithAckTs(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendAckTimeout = time.Second
+	sent := make(chan string, 1)
+	bot.OnMessageSent = func(channel, text, ts string) { sent <- ts }
+	go bot.listen()
+	go func() {
+		var message messageOut
+		json.Unmarshal(<-c.sent, &message)
+		c.push(fmt.Sprintf(`{"ok":true,"reply_to":%d,"ts":"7.5","text":"hello"}`, message.ID))
+	}()
+	if err := bot.SendMessage("C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ts := <-sent:
+		if ts != "7.5" {
+			t.Errorf("OnMessageSent got ts %q, want the acknowledged one", ts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnMessageSent not called")
+	}
+}
+
+func TestEchoSentMessages(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.EchoSentMessages = true
+	echoes := make(chan MessageIn, 1)
+	bot.OnMessage = func(event MessageIn) error {
+		echoes <- event
+		return nil
+	}
+	go bot.listen()
+	go func() {
+		var message messageOut
+		json.Unmarshal(<-c.sent, &message)
+		c.push(fmt.Sprintf(`{"ok":true,"reply_to":%d,"ts":"3.5","text":"echo me"}`, message.ID))
+	}()
+	if err := bot.SendMessage("C1", "echo me"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case echo := <-echoes:
+		if echo.Text != "echo me" || echo.Channel != "C1" || echo.User != "UBOT" || echo.Ts != "3.5" {
+			t.Errorf("echoed as %+v", echo)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sent message not echoed")
+	}
+}
+
+func TestLongMessagesAreSplit(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	var text strings.Builder
+	for i := 0; text.Len() < 100000; i++ {
+		fmt.Fprintf(&text, "line %d of a long log\n", i)
+	}
+	if err := bot.SendMessage("C1", text.String()); err != nil {
+		t.Fatal(err)
+	}
+	var joined string
+	for len(joined) < text.Len() {
+		part := c.next(t)["text"].(string)
+		if utf8.RuneCountInString(part) > bot.MaxMessageLength {
+			t.Errorf("sent a part of %d characters", utf8.RuneCountInString(part))
+		}
+		if !strings.HasSuffix(part, "\n") {
+			t.Errorf("part not split at a line boundary: ...%s", part[len(part)-20:])
+		}
+		joined += part
+	}
+	if joined != text.String() {
+		t.Error("parts do not add up to the message")
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	for _, test := range []struct {
+		message string
+		max     int
+		want    []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"one two three", 8, []string{"one two ", "three"}},
+		{"a\nbc def", 6, []string{"a\n", "bc def"}},
+		{"a\nbc defg", 6, []string{"a\n", "bc ", "defg"}},
+		{"abcdefgh", 3, []string{"abc", "def", "gh"}},
+		{"ææææ", 2, []string{"ææ", "ææ"}},
+		{"anything", 0, []string{"anything"}},
+	} {
+		got := splitMessage(test.message, test.max)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("splitMessage(%q, %d) = %q, want %q", test.message, test.max, got, test.want)
+		}
+	}
+}
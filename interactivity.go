@@ -0,0 +1,148 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// InteractionCallback represents the payload sent by Slack when a user interacts
+// with a message, e.g. by clicking a button. Interactions are sent over HTTP
+// rather than the RTM connection, and are received through InteractivityHandler.
+// Slack API doc: https://api.slack.com/reference/interaction-payloads/block-actions
+type InteractionCallback struct {
+	Type        string `json:"type"` // Either "block_actions" or "interactive_message"
+	CallbackID  string `json:"callback_id"`
+	TriggerID   string `json:"trigger_id"`
+	ResponseURL string `json:"response_url"`
+	ActionTs    string `json:"action_ts"`
+	User        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	Team struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	Actions []Action `json:"actions"`
+}
+
+// Action represents a single action taken by a user as part of an interaction.
+// Block actions are identified by ActionID and BlockID, while actions on legacy
+// interactive messages are identified by Name.
+type Action struct {
+	Type     string `json:"type"`
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	ActionTs string `json:"action_ts"`
+}
+
+// InteractivityHandler returns an HTTP handler for the interactivity request URL
+// configured for the Slack app. It verifies that requests are signed by Slack using
+// the app's signing secret, and calls OnBlockActions or OnInteractiveMessage
+// depending on the type of interaction. As Slack shows an error to the user
+// unless it gets a response within three seconds, the request is answered
+// right away and the callbacks are called afterwards, on a goroutine of their
+// own. Errors returned by the callbacks are reported like those of any other
+// callback.
+func (bot *SlackBot) InteractivityHandler(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifySignature(signingSecret, r); err != nil {
+			bot.logger.Println("Rejecting interaction:", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var interaction InteractionCallback
+		if err := json.Unmarshal([]byte(r.PostFormValue("payload")), &interaction); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		go bot.handleInteraction(interaction)
+	})
+}
+
+// handleInteraction calls OnBlockActions or OnInteractiveMessage for a given
+// interaction, depending on its type, and reports any errors.
+func (bot *SlackBot) handleInteraction(callback InteractionCallback) {
+	bot.logger.Println("Received interaction: " + callback.Type)
+	defer bot.watchCallback(callback.Type)()
+	if err := (interaction{callback}).invoke(bot); err != nil {
+		bot.reportError(err)
+	}
+}
+
+// interaction is an interaction handled like an event, as is the case for
+// those received through Socket Mode, so that it is held while the bot is
+// paused and filtered by channel. It is passed to no callbacks other than
+// its own, not even OnEvent.
+type interaction struct {
+	callback InteractionCallback
+}
+
+// EventType returns the type of the interaction, e.g. "block_actions".
+func (event interaction) EventType() string { return event.callback.Type }
+
+func (event interaction) invoke(bot *SlackBot) (err error) {
+	switch event.callback.Type {
+	case "block_actions":
+		if bot.OnBlockActions != nil {
+			err = bot.OnBlockActions(event.callback)
+		}
+	case "interactive_message":
+		if bot.OnInteractiveMessage != nil {
+			err = bot.OnInteractiveMessage(event.callback)
+		}
+	}
+	return
+}
+
+// Response represents a message sent to the response_url of an interaction,
+// e.g. to update the message that was interacted with.
+// Slack API doc: https://api.slack.com/interactivity/handling#message_responses
+type Response struct {
+	Text            string `json:"text,omitempty"`
+	ResponseType    string `json:"response_type,omitempty"`    // Either "in_channel" or "ephemeral", the default
+	ReplaceOriginal bool   `json:"replace_original,omitempty"` // Replace the message that was interacted with
+	DeleteOriginal  bool   `json:"delete_original,omitempty"`  // Delete the message that was interacted with
+	ThreadTs        string `json:"thread_ts,omitempty"`
+}
+
+// RespondURL posts a given response to a response_url provided by Slack
+// in an interaction payload. In dry-run mode, the response is recorded
+// instead, with the response URL in place of a channel, as it is given to
+// OnMessageSent.
+func (bot *SlackBot) RespondURL(responseURL string, response Response) (err error) {
+	if bot.DryRun {
+		bot.recordDryRun(responseURL, response.Text)
+		return
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", responseURL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bot.UserAgent != "" {
+		req.Header.Set("User-Agent", bot.UserAgent)
+	}
+	resp, err := bot.httpClient().Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return StatusError{Method: "response_url", StatusCode: resp.StatusCode}
+	}
+	bot.messageSent(responseURL, response.Text, "")
+	return
+}
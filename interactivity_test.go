@@ -0,0 +1,118 @@
+package slackbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSignedRequest returns a request with a given body, signed at a given
+// time with a given signing secret as Slack would.
+func newSignedRequest(secret, body string, at time.Time) *http.Request {
+	r := httptest.NewRequest("POST", "/interactivity", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestInteractivityHandlerDispatchesBlockActions(t *testing.T) {
+	bot := New(newTestLogger())
+	received := make(chan InteractionCallback, 1)
+	bot.OnBlockActions = func(interaction InteractionCallback) error {
+		received <- interaction
+		return nil
+	}
+	payload := `{"type":"block_actions","user":{"id":"U1"},"actions":[{"action_id":"approve","value":"yes"}]}`
+	w := httptest.NewRecorder()
+	bot.InteractivityHandler("secret").ServeHTTP(w, newSignedRequest("secret", "payload="+url.QueryEscape(payload), time.Now()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	select {
+	case interaction := <-received:
+		if interaction.User.ID != "U1" || len(interaction.Actions) != 1 || interaction.Actions[0].ActionID != "approve" {
+			t.Errorf("interaction parsed as %+v", interaction)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnBlockActions not called")
+	}
+}
+
+func TestInteractivityHandlerDispatchesInteractiveMessages(t *testing.T) {
+	bot := New(newTestLogger())
+	received := make(chan InteractionCallback, 1)
+	bot.OnInteractiveMessage = func(interaction InteractionCallback) error {
+		received <- interaction
+		return nil
+	}
+	bot.OnBlockActions = func(interaction InteractionCallback) error {
+		t.Error("OnBlockActions called for an interactive message")
+		return nil
+	}
+	payload := `{"type":"interactive_message","callback_id":"c1","actions":[{"name":"approve"}]}`
+	w := httptest.NewRecorder()
+	bot.InteractivityHandler("secret").ServeHTTP(w, newSignedRequest("secret", "payload="+url.QueryEscape(payload), time.Now()))
+	select {
+	case interaction := <-received:
+		if interaction.CallbackID != "c1" || interaction.Actions[0].Name != "approve" {
+			t.Errorf("interaction parsed as %+v", interaction)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnInteractiveMessage not called")
+	}
+}
+
+func TestInteractivityHandlerRejectsBadSignatures(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.OnBlockActions = func(interaction InteractionCallback) error {
+		t.Error("callback called for an unsigned request")
+		return nil
+	}
+	body := "payload=" + url.QueryEscape(`{"type":"block_actions"}`)
+	w := httptest.NewRecorder()
+	bot.InteractivityHandler("secret").ServeHTTP(w, newSignedRequest("other", body, time.Now()))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want 401", w.Code)
+	}
+}
+
+func TestRespondURL(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("response_url", `{"ok":true}`)
+	err := bot.RespondURL(api.server.URL+"/api/response_url", Response{
+		Text:            "Approved",
+		ResponseType:    "in_channel",
+		ReplaceOriginal: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("response_url")
+	if len(calls) != 1 {
+		t.Fatalf("got %d responses, want 1", len(calls))
+	}
+	if body := string(calls[0].Body); body != `{"text":"Approved","response_type":"in_channel","replace_original":true}` {
+		t.Errorf("response serialized as %s", body)
+	}
+	if calls[0].Header.Get("Content-Type") != "application/json" || calls[0].Header.Get("Authorization") != "" {
+		t.Errorf("response sent with headers %v", calls[0].Header)
+	}
+	api.reply("response_url", apiStatus{Code: http.StatusNotFound})
+	if err := bot.RespondURL(api.server.URL+"/api/response_url", Response{DeleteOriginal: true}); err == nil {
+		t.Error("expired response URL gave no error")
+	}
+	if body := string(api.callsTo("response_url")[1].Body); body != `{"delete_original":true}` {
+		t.Errorf("response serialized as %s", body)
+	}
+}
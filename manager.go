@@ -0,0 +1,148 @@
+package slackbot
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Manager runs several bots from a single process, typically one per Slack
+// workspace, combining their events, errors and disconnection signals and
+// shutting them down together. Bots are set up as usual, including their
+// callbacks, and are then added to the manager instead of being started
+// individually.
+type Manager struct {
+	Events chan WorkspaceEvent // Signals events received by any of the bots; see below
+	Errors chan WorkspaceError // Signals errors seen on callbacks of any of the bots
+	Done   chan bool           // Signals that all bots have disconnected
+
+	// Events are passed on to Events, which must then be read, from the
+	// OnEvent callback of each bot, after which the bot's own OnEvent, if
+	// any, is called. As Events is unbuffered, a bot does not dispatch any
+	// further events until the previous one has been read, so Events must be
+	// drained for as long as the bots run. If Events is set to nil before
+	// Start, events are only passed to the callbacks of the bots.
+
+	mutex      sync.Mutex
+	workspaces []string             // Names of the workspaces in the order they were added
+	bots       map[string]*SlackBot // Bots by workspace
+	tokens     map[string]string    // Tokens by workspace
+	forwarding map[*SlackBot]bool   // Bots already passing on their events to Events
+	running    sync.WaitGroup       // Bots that have not yet disconnected
+}
+
+// WorkspaceEvent is an event received by the bot identified by Workspace
+// in a Manager.
+type WorkspaceEvent struct {
+	Workspace string
+	Event     Event
+}
+
+// WorkspaceError is an error returned by a callback of the bot identified
+// by Workspace in a Manager.
+type WorkspaceError struct {
+	Workspace string
+	Err       error
+}
+
+func (err WorkspaceError) Error() string {
+	return err.Workspace + ": " + err.Err.Error()
+}
+
+// NewManager creates a new Manager without any bots.
+func NewManager() *Manager {
+	return &Manager{
+		Events:     make(chan WorkspaceEvent),
+		Errors:     make(chan WorkspaceError),
+		Done:       make(chan bool, 1),
+		bots:       make(map[string]*SlackBot),
+		tokens:     make(map[string]string),
+		forwarding: make(map[*SlackBot]bool),
+	}
+}
+
+// Add adds a bot to the manager, identified by a given workspace name,
+// which will be started with a given token.
+func (manager *Manager) Add(workspace, token string, bot *SlackBot) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if _, exists := manager.bots[workspace]; !exists {
+		manager.workspaces = append(manager.workspaces, workspace)
+	}
+	manager.bots[workspace] = bot
+	manager.tokens[workspace] = token
+}
+
+// Bot returns the bot for a given workspace, or nil if there is none.
+func (manager *Manager) Bot(workspace string) *SlackBot {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	return manager.bots[workspace]
+}
+
+// Start starts all bots in the order they were added. If any bot fails to
+// start, those already started are stopped again and the error is returned,
+// after which Start may be called again.
+func (manager *Manager) Start() (err error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	for i, workspace := range manager.workspaces {
+		bot := manager.bots[workspace]
+		if manager.Events != nil && !manager.forwarding[bot] {
+			manager.forwardEvents(workspace, bot)
+		}
+		if err = bot.Start(manager.tokens[workspace]); err != nil {
+			for _, started := range manager.workspaces[:i] {
+				manager.bots[started].Disconnect()
+			}
+			return fmt.Errorf("starting bot for %s: %v", workspace, err)
+		}
+		manager.running.Add(1)
+		go manager.forward(workspace, bot)
+	}
+	go func() {
+		manager.running.Wait()
+		select {
+		case manager.Done <- true:
+		default:
+		}
+	}()
+	return
+}
+
+// forwardEvents makes a given bot pass on its events to Events before
+// calling its own OnEvent callback. It is only done once for each bot, so
+// that starting it again does not pass on its events twice.
+func (manager *Manager) forwardEvents(workspace string, bot *SlackBot) {
+	manager.forwarding[bot] = true
+	onEvent := bot.OnEvent
+	bot.OnEvent = func(event Event) error {
+		manager.Events <- WorkspaceEvent{Workspace: workspace, Event: event}
+		if onEvent != nil {
+			return onEvent(event)
+		}
+		return nil
+	}
+}
+
+// forward passes on the errors of a given bot until it disconnects.
+func (manager *Manager) forward(workspace string, bot *SlackBot) {
+	defer manager.running.Done()
+	for {
+		select {
+		case err := <-bot.CallbackErrors:
+			manager.Errors <- WorkspaceError{Workspace: workspace, Err: err}
+		case <-bot.Done:
+			return
+		}
+	}
+}
+
+// Stop disconnects all bots. Bots that are not connected, e.g. because
+// Start failed before reaching them, are skipped.
+func (manager *Manager) Stop() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	for _, workspace := range manager.workspaces {
+		manager.bots[workspace].Disconnect()
+	}
+}
@@ -0,0 +1,143 @@
+package slackbot
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestManagerTagsEventsAndStopsAllBots(t *testing.T) {
+	manager := NewManager()
+	sockets := make(map[string]*websocket.Conn)
+	apis := make(map[string]*fakeAPI)
+	for _, workspace := range []string{"alpha", "beta"} {
+		bot, api := newTestBot(t)
+		api.serveRTM()
+		apis[workspace] = api
+		manager.Add(workspace, "xoxb-"+workspace, bot)
+	}
+	if err := manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for workspace, api := range apis {
+		sockets[workspace] = api.socket(t)
+		if auth := api.callsTo("rtm.connect")[0].Header.Get("Authorization"); auth != "Bearer xoxb-"+workspace {
+			t.Errorf("%s started with %q", workspace, auth)
+		}
+	}
+	for _, workspace := range []string{"alpha", "beta"} {
+		websocket.Message.Send(sockets[workspace], `{"type":"presence_change","user":"U-`+workspace+`","presence":"away"}`)
+		select {
+		case event := <-manager.Events:
+			presence, ok := event.Event.(PresenceChange)
+			if event.Workspace != workspace || !ok || presence.User != "U-"+workspace {
+				t.Errorf("got %+v, want the event from %s", event, workspace)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event received from %s", workspace)
+		}
+	}
+	manager.Stop()
+	select {
+	case <-manager.Done:
+	case <-time.After(time.Second):
+		t.Fatal("Done not signalled after Stop")
+	}
+	for workspace, ws := range sockets {
+		// Skip the pings sent before disconnecting
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var message string
+		var err error
+		for err == nil {
+			err = websocket.Message.Receive(ws, &message)
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Errorf("connection to %s still open", workspace)
+		}
+	}
+}
+
+func TestManagerTagsErrors(t *testing.T) {
+	manager := NewManager()
+	manager.Events = nil
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	bot.OnEvent = func(event Event) error { return errors.New("failed") }
+	manager.Add("alpha", "xoxb-test", bot)
+	if err := manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Stop()
+	websocket.Message.Send(api.socket(t), `{"type":"presence_change","user":"U1","presence":"away"}`)
+	select {
+	case err := <-manager.Errors:
+		if err.Workspace != "alpha" || err.Error() != "alpha: failed" {
+			t.Errorf("got error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no error received")
+	}
+}
+
+func TestManagerStopSkipsUnstartedBots(t *testing.T) {
+	manager := NewManager()
+	failing, api := newTestBot(t)
+	api.reply("rtm.connect", `{"ok":false,"error":"invalid_auth"}`)
+	manager.Add("alpha", "xoxb-test", failing)
+	manager.Add("beta", "xoxb-test", New(newTestLogger()))
+	if err := manager.Start(); err == nil {
+		t.Fatal("Start succeeded with an invalid token")
+	}
+	manager.Stop()
+}
+
+func TestManagerRestartForwardsEventsOnce(t *testing.T) {
+	manager := NewManager()
+	alpha, alphaAPI := newTestBot(t)
+	alphaAPI.serveRTM()
+	handled := make(chan Event, 2)
+	alpha.OnEvent = func(event Event) error {
+		handled <- event
+		return nil
+	}
+	beta, betaAPI := newTestBot(t)
+	betaAPI.reply("rtm.connect", `{"ok":false,"error":"invalid_auth"}`)
+	manager.Add("alpha", "xoxb-alpha", alpha)
+	manager.Add("beta", "xoxb-beta", beta)
+	if err := manager.Start(); err == nil {
+		t.Fatal("Start succeeded with an invalid token")
+	}
+	alphaAPI.socket(t)
+	betaAPI.serveRTM()
+	if err := manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Stop()
+	betaAPI.socket(t)
+	websocket.Message.Send(alphaAPI.socket(t), `{"type":"presence_change","user":"U1","presence":"away"}`)
+	select {
+	case event := <-manager.Events:
+		if _, ok := event.Event.(PresenceChange); !ok || event.Workspace != "alpha" {
+			t.Errorf("got %+v, want the event from alpha", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	select {
+	case event := <-manager.Events:
+		t.Errorf("event passed on again: %+v", event)
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("OnEvent of the bot not called")
+	}
+	select {
+	case event := <-manager.Events:
+		t.Errorf("event passed on again: %+v", event)
+	case event := <-handled:
+		t.Errorf("OnEvent of the bot called again with %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,47 @@
+package slackbot
+
+import (
+	"net/url"
+	"time"
+)
+
+// markReadDelay is the time the bot waits after processing a message before
+// marking it as read, so that a burst of messages in a channel only causes
+// a single call to conversations.mark.
+const markReadDelay = 3 * time.Second
+
+// markRead marks a given message, and thereby all earlier messages in its
+// channel, as read after markReadDelay, unless a later message in the channel
+// is processed in the meantime.
+func (bot *SlackBot) markRead(channel, ts string) {
+	bot.markMutex.Lock()
+	defer bot.markMutex.Unlock()
+	if bot.unmarked == nil {
+		bot.unmarked = make(map[string]string)
+	}
+	latest, waiting := bot.unmarked[channel]
+	if !waiting || tsLess(latest, ts) {
+		bot.unmarked[channel] = ts
+	}
+	if !waiting {
+		time.AfterFunc(markReadDelay, func() { bot.flushMarkRead(channel) })
+	}
+}
+
+// flushMarkRead calls conversations.mark for the latest processed message
+// in a given channel.
+// Slack API doc: https://api.slack.com/methods/conversations.mark
+func (bot *SlackBot) flushMarkRead(channel string) {
+	bot.markMutex.Lock()
+	ts := bot.unmarked[channel]
+	delete(bot.unmarked, channel)
+	bot.markMutex.Unlock()
+	params := url.Values{
+		"channel": {channel},
+		"ts":      {ts},
+	}
+	var resp apiResponse
+	if err := bot.callAPI("conversations.mark", params, &resp); err != nil {
+		bot.logger.Printf("Marking channel %s as read failed: %v\n", channel, err)
+	}
+}
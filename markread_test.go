@@ -0,0 +1,49 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoMarkReadIsDebounced(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.AutoMarkRead = true
+	marked := make(chan apiCall, 10)
+	api.handle("conversations.mark", func(call apiCall) interface{} {
+		marked <- call
+		return `{"ok":true}`
+	})
+	bot.OnMessage = func(event MessageIn) error { return nil }
+	for _, ts := range []string{"1.0", "3.0", "2.0"} {
+		bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"` + ts + `"}`))
+	}
+	bot.handleEvent([]byte(`{"type":"message","channel":"C2","user":"U1","text":"hi","ts":"5.0"}`))
+	latest := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case call := <-marked:
+			latest[call.Form.Get("channel")] = call.Form.Get("ts")
+		case <-time.After(markReadDelay + time.Second):
+			t.Fatal("messages not marked as read")
+		}
+	}
+	if latest["C1"] != "3.0" || latest["C2"] != "5.0" {
+		t.Errorf("marked %v, want the latest message of each channel", latest)
+	}
+	select {
+	case call := <-marked:
+		t.Errorf("marked again: %v", call.Form)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAutoMarkReadDisabledByDefault(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.OnMessage = func(event MessageIn) error { return nil }
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0"}`))
+	bot.markMutex.Lock()
+	defer bot.markMutex.Unlock()
+	if len(bot.unmarked) != 0 || len(api.callsTo("conversations.mark")) != 0 {
+		t.Error("message marked as read without AutoMarkRead")
+	}
+}
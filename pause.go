@@ -0,0 +1,50 @@
+package slackbot
+
+// Pause stops the bot from calling callbacks for events until Resume is
+// called, while staying connected. Events received in the meantime are kept
+// and delivered on Resume, unless DiscardWhilePaused is set, as are
+// interactions and slash commands received through Socket Mode. Events used
+// internally by the bot, such as pongs, are still handled.
+func (bot *SlackBot) Pause() {
+	bot.pauseMutex.Lock()
+	defer bot.pauseMutex.Unlock()
+	bot.paused = true
+}
+
+// Resume makes the bot call callbacks for events again after Pause, first
+// delivering any events kept while paused, in the order they arrived.
+func (bot *SlackBot) Resume() {
+	for {
+		bot.pauseMutex.Lock()
+		held := bot.held
+		bot.held = nil
+		if len(held) == 0 {
+			bot.paused = false
+			bot.pauseMutex.Unlock()
+			return
+		}
+		bot.pauseMutex.Unlock()
+		// New events are still held while these are delivered, so that
+		// they do not overtake them
+		for _, event := range held {
+			bot.dispatch(event)
+		}
+	}
+}
+
+// hold reports whether the bot is paused, in which case a given event is
+// kept for later rather than dispatched.
+func (bot *SlackBot) hold(event event) bool {
+	if isInternal(event) {
+		return false
+	}
+	bot.pauseMutex.Lock()
+	defer bot.pauseMutex.Unlock()
+	if !bot.paused {
+		return false
+	}
+	if !bot.DiscardWhilePaused {
+		bot.held = append(bot.held, event)
+	}
+	return true
+}
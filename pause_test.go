@@ -0,0 +1,52 @@
+package slackbot
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestPauseHoldsEventsUntilResume(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []string
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event.Text)
+		return nil
+	}
+	bot.Pause()
+	for i := 0; i < 5; i++ {
+		bot.handleEvent([]byte(fmt.Sprintf(`{"type":"message","channel":"C1","user":"U1","text":"%d"}`, i)))
+	}
+	if len(received) != 0 {
+		t.Fatalf("callbacks called while paused: %v", received)
+	}
+	bot.Resume()
+	if len(received) != 5 {
+		t.Fatalf("got %d events after resuming, want 5", len(received))
+	}
+	for i, text := range received {
+		if text != strconv.Itoa(i) {
+			t.Fatalf("held events delivered in order %v", received)
+		}
+	}
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"after"}`))
+	if len(received) != 6 {
+		t.Error("events not delivered right away after resuming")
+	}
+}
+
+func TestDiscardWhilePaused(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.DiscardWhilePaused = true
+	calls := 0
+	bot.OnMessage = func(event MessageIn) error {
+		calls++
+		return nil
+	}
+	bot.Pause()
+	bot.handleEvent([]byte(`{"type":"message","channel":"C1","user":"U1","text":"dropped"}`))
+	bot.Resume()
+	if calls != 0 {
+		t.Errorf("discarded event delivered %d times", calls)
+	}
+}
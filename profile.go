@@ -0,0 +1,31 @@
+package slackbot
+
+import "time"
+
+// statusProfile holds the fields of a user profile that make up a custom status.
+type statusProfile struct {
+	StatusText       string `json:"status_text"`
+	StatusEmoji      string `json:"status_emoji"`
+	StatusExpiration int64  `json:"status_expiration"`
+}
+
+// SetStatus sets the custom status of the bot to a given text and emoji, such
+// as ":hourglass:", until a given expiration time. A zero expiration time
+// makes the status last until it is changed.
+// Slack API doc: https://api.slack.com/methods/users.profile.set
+func (bot *SlackBot) SetStatus(text, emoji string, expiration time.Time) error {
+	profile := statusProfile{StatusText: text, StatusEmoji: emoji}
+	if !expiration.IsZero() {
+		profile.StatusExpiration = expiration.Unix()
+	}
+	params := struct {
+		Profile statusProfile `json:"profile"`
+	}{profile}
+	var resp apiResponse
+	return bot.callAPIJSON("users.profile.set", params, &resp)
+}
+
+// ClearStatus clears the custom status of the bot.
+func (bot *SlackBot) ClearStatus() error {
+	return bot.SetStatus("", "", time.Time{})
+}
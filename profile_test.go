@@ -0,0 +1,49 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// sentStatus returns the profile sent in a given call to users.profile.set.
+func sentStatus(t *testing.T, call apiCall) (profile map[string]interface{}) {
+	t.Helper()
+	var body struct{ Profile map[string]interface{} }
+	if err := json.Unmarshal(call.Body, &body); err != nil {
+		t.Fatalf("users.profile.set sent %s", call.Body)
+	}
+	return body.Profile
+}
+
+func TestSetAndClearStatus(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("users.profile.set", `{"ok":true,"profile":{}}`)
+	if err := bot.SetStatus("Processing", ":hourglass:", time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.ClearStatus(); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("users.profile.set")
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls to users.profile.set, want 2", len(calls))
+	}
+	set := sentStatus(t, calls[0])
+	if set["status_text"] != "Processing" || set["status_emoji"] != ":hourglass:" || set["status_expiration"] != 1700000000.0 {
+		t.Errorf("status set with profile %v", set)
+	}
+	// Clearing sends empty fields rather than omitting them
+	cleared := sentStatus(t, calls[1])
+	if cleared["status_text"] != "" || cleared["status_emoji"] != "" || cleared["status_expiration"] != 0.0 {
+		t.Errorf("status cleared with profile %v", cleared)
+	}
+}
+
+func TestSetStatusError(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("users.profile.set", `{"ok":false,"error":"too_long"}`)
+	if apiErr, ok := bot.SetStatus("Processing", ":hourglass:", time.Time{}).(APIError); !ok || apiErr.Code != "too_long" {
+		t.Error("too_long not surfaced")
+	}
+}
@@ -0,0 +1,156 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// queueIdleTimeout is how long the queue of a channel is kept once empty.
+const queueIdleTimeout = time.Minute
+
+// sendRequest is a message waiting to be sent, either over the RTM
+// connection or, if post is set, through the Web API.
+type sendRequest struct {
+	ctx     context.Context
+	channel string
+	message *messageOut  // The message to write to the RTM connection
+	post    func() error // Sends the message through the Web API in place of writing it
+	sent    chan error   // Receives the result of sending the message
+	typing  bool         // Is true for typing indicators, which SendRate does not apply to
+}
+
+// channelQueue holds the messages waiting to be sent to a single channel.
+// Messages leave the queue in order, spaced out according to SendRate.
+type channelQueue struct {
+	channel   string
+	requests  chan *sendRequest
+	limiter   rateLimiter
+	enqueuing int // Number of messages being added to the queue, guarded by the queue mutex
+}
+
+// enqueue adds a message to the queue of its channel, creating the queue
+// if needed. Queued messages are passed on, one at a time, to the single
+// goroutine writing to the connection. Queues are removed once they have
+// been idle for a while, so that channels messaged only once do not keep
+// a queue around. If the queue is full, enqueue waits for room in it, or
+// gives up on the message once its context is done.
+func (bot *SlackBot) enqueue(request *sendRequest) {
+	bot.writerOnce.Do(func() {
+		bot.writes = make(chan *sendRequest)
+		go bot.write()
+	})
+	bot.queueMutex.Lock()
+	if bot.queues == nil {
+		bot.queues = make(map[string]*channelQueue)
+	}
+	channel := request.channel
+	queue, exists := bot.queues[channel]
+	if !exists {
+		queue = &channelQueue{channel: channel, requests: make(chan *sendRequest, 64)}
+		bot.queues[channel] = queue
+		go bot.drain(queue)
+	}
+	// Keep the queue from being removed until the message is in it
+	queue.enqueuing++
+	bot.queueMutex.Unlock()
+	bot.counters.addPending(1)
+	select {
+	case queue.requests <- request:
+	case <-request.ctx.Done():
+		bot.counters.addPending(-1)
+		if request.message != nil {
+			bot.takePending(request.message.ID)
+		}
+		request.sent <- request.ctx.Err()
+	}
+	bot.queueMutex.Lock()
+	queue.enqueuing--
+	bot.queueMutex.Unlock()
+}
+
+// drain passes on the messages of a queue to the writer, or posts them, as
+// the rate limit allows, skipping those whose context is done. Typing
+// indicators are passed on right away. It removes the queue and returns once
+// the queue has been idle for queueIdleTimeout.
+func (bot *SlackBot) drain(queue *channelQueue) {
+	idle := time.NewTimer(queueIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case request := <-queue.requests:
+			if request.typing {
+				bot.writes <- request
+			} else if err := queue.limiter.wait(request.ctx, bot.sendInterval()); err != nil {
+				bot.counters.addPending(-1)
+				if request.message != nil {
+					bot.takePending(request.message.ID)
+				}
+				request.sent <- err
+			} else if request.post != nil {
+				// Posting in turn keeps the messages to the channel in order
+				bot.counters.addPending(-1)
+				request.sent <- request.post()
+			} else {
+				bot.writes <- request
+			}
+		case <-idle.C:
+			if bot.removeIdleQueue(queue) {
+				return
+			}
+		}
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(queueIdleTimeout)
+	}
+}
+
+// throttle calls a given function sending a message to a given channel
+// through the Web API once the rate limit of the channel allows it, in turn
+// with the other messages to the channel, and returns its result.
+func (bot *SlackBot) throttle(channel string, post func() error) error {
+	request := &sendRequest{ctx: context.Background(), channel: channel, post: post, sent: make(chan error, 1)}
+	bot.enqueue(request)
+	return <-request.sent
+}
+
+// removeIdleQueue removes a given queue and reports whether it did, which
+// is only the case if no messages are waiting in it or being added to it,
+// and the rate limit would not hold back the next message.
+func (bot *SlackBot) removeIdleQueue(queue *channelQueue) bool {
+	bot.queueMutex.Lock()
+	defer bot.queueMutex.Unlock()
+	if queue.enqueuing > 0 || len(queue.requests) > 0 || !queue.limiter.idle() {
+		return false
+	}
+	delete(bot.queues, queue.channel)
+	return true
+}
+
+// write writes queued messages to the current connection, one at a time.
+// Messages that are not written are forgotten, as they will never be
+// acknowledged, even if their sender no longer waits for the result.
+func (bot *SlackBot) write() {
+	for request := range bot.writes {
+		bot.counters.addPending(-1)
+		bot.writeMutex.Lock()
+		err := request.ctx.Err()
+		if err == nil {
+			ws := bot.currentConn()
+			if ws == nil {
+				err = errors.New("bot is not connected")
+			} else if err = ws.Send(request.message); err == nil {
+				bot.markWritten(request.message.ID, ws)
+			}
+		}
+		if err != nil {
+			bot.takePending(request.message.ID)
+		}
+		bot.writeMutex.Unlock()
+		request.sent <- err
+	}
+}
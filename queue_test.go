@@ -0,0 +1,105 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannelQueuesAreIndependent(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendRate = 5
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, channel := range []string{"C1", "C2"} {
+		wg.Add(1)
+		go func(channel string) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				if err := bot.SendMessage(channel, fmt.Sprintf("%s-%d", channel, i)); err != nil {
+					t.Error(err)
+				}
+			}
+		}(channel)
+	}
+	received := make(map[string][]string)
+	for i := 0; i < 6; i++ {
+		message := c.next(t)
+		channel := message["channel"].(string)
+		received[channel] = append(received[channel], message["text"].(string))
+	}
+	wg.Wait()
+	// A single queue would take five intervals of 200ms
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Errorf("sending to two channels took %v", elapsed)
+	}
+	for _, channel := range []string{"C1", "C2"} {
+		for i, text := range received[channel] {
+			if want := fmt.Sprintf("%s-%d", channel, i); text != want {
+				t.Errorf("messages to %s sent in order %v", channel, received[channel])
+				break
+			}
+		}
+	}
+}
+
+func TestIdleQueuesAreRemoved(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendRate = 20
+	if err := bot.SendMessage("C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	c.next(t)
+	bot.queueMutex.Lock()
+	queue := bot.queues["C1"]
+	bot.queueMutex.Unlock()
+	if queue == nil {
+		t.Fatal("no queue created")
+	}
+	if bot.removeIdleQueue(queue) {
+		t.Error("queue removed while the rate limit holds back the next message")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !bot.removeIdleQueue(queue) {
+		t.Fatal("idle queue not removed")
+	}
+	bot.queueMutex.Lock()
+	_, exists := bot.queues["C1"]
+	bot.queueMutex.Unlock()
+	if exists {
+		t.Error("removed queue still in use")
+	}
+	// Sending again creates a new queue
+	if err := bot.SendMessage("C1", "again"); err != nil {
+		t.Fatal(err)
+	}
+	if sent := c.next(t); sent["text"] != "again" {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestSendMessageContextCancelledWhileQueueFull(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.OnCallbackError = func(err error) {}
+	c.gate = make(chan struct{})
+	// One message is held by the writer, one by the queue waiting for the
+	// writer, and the rest fill the queue
+	for i := 0; i < 66; i++ {
+		bot.SendAsync("C1", fmt.Sprint("filler ", i))
+	}
+	eventually(t, func() bool { return bot.PendingSends() == 65 }, "queue not filled")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bot.SendMessageContext(ctx, "C1", "too late"); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, long after the deadline", elapsed)
+	}
+	if pending := bot.PendingSends(); pending != 65 {
+		t.Errorf("%d sends pending, want the given up message not counted", pending)
+	}
+}
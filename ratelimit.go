@@ -0,0 +1,192 @@
+package slackbot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitCooldown is the default value of SlackBot.RateLimitCooldown.
+const defaultRateLimitCooldown = time.Minute
+
+// rateLimiter spaces out messages sent by the bot.
+type rateLimiter struct {
+	mutex sync.Mutex
+	next  time.Time // Earliest time at which the next message may be sent
+}
+
+// wait blocks until a given interval has passed since the last message, or
+// until the context is done.
+func (limiter *rateLimiter) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	limiter.mutex.Lock()
+	now := time.Now()
+	at := limiter.next
+	if at.Before(now) {
+		at = now
+	}
+	limiter.next = at.Add(interval)
+	limiter.mutex.Unlock()
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// idle reports whether the limiter would let a message through right away,
+// so that it may be discarded without losing track of the rate.
+func (limiter *rateLimiter) idle() bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return !time.Now().Before(limiter.next)
+}
+
+// minAdaptiveRate is the fraction of SendRate below which the adaptive send
+// rate is not lowered.
+const minAdaptiveRate = 1.0 / 64
+
+// adaptiveRate is the send rate used when AdaptiveSendRate is set, which is
+// halved when the bot is rate limited and recovers additively over time.
+type adaptiveRate struct {
+	mutex sync.Mutex
+	rate  float64   // Rate at the given time, or zero if at SendRate
+	at    time.Time // Time at which the rate was last lowered
+}
+
+// current returns the rate at a given time, given the maximum rate and the
+// time taken to recover a tenth of it. It must be called with the mutex held.
+func (adaptive *adaptiveRate) current(max float64, recovery time.Duration, now time.Time) float64 {
+	if adaptive.rate == 0 {
+		return max
+	}
+	rate := adaptive.rate + max/10*float64(now.Sub(adaptive.at))/float64(recovery)
+	if rate >= max {
+		adaptive.rate = 0
+		return max
+	}
+	return rate
+}
+
+// get returns the current rate, given the maximum rate and the time taken to
+// recover a tenth of it.
+func (adaptive *adaptiveRate) get(max float64, recovery time.Duration) float64 {
+	adaptive.mutex.Lock()
+	defer adaptive.mutex.Unlock()
+	return adaptive.current(max, recovery, time.Now())
+}
+
+// halve halves the current rate, given the maximum rate and the time taken
+// to recover a tenth of it.
+func (adaptive *adaptiveRate) halve(max float64, recovery time.Duration) {
+	adaptive.mutex.Lock()
+	defer adaptive.mutex.Unlock()
+	now := time.Now()
+	rate := adaptive.current(max, recovery, now) / 2
+	if rate < max*minAdaptiveRate {
+		rate = max * minAdaptiveRate
+	}
+	adaptive.rate = rate
+	adaptive.at = now
+}
+
+// rateLimitCooldown returns RateLimitCooldown, or its default if unset.
+func (bot *SlackBot) rateLimitCooldown() time.Duration {
+	if bot.RateLimitCooldown <= 0 {
+		return defaultRateLimitCooldown
+	}
+	return bot.RateLimitCooldown
+}
+
+// sendInterval returns the time to wait between messages sent to the same
+// channel according to SendRate, doubled if the bot was recently rate limited,
+// or adapted to rate limiting if AdaptiveSendRate is set.
+func (bot *SlackBot) sendInterval() time.Duration {
+	if bot.SendRate <= 0 {
+		return 0
+	}
+	if bot.AdaptiveSendRate {
+		rate := bot.adaptiveRate.get(bot.SendRate, bot.rateLimitCooldown())
+		return time.Duration(float64(time.Second) / rate)
+	}
+	interval := time.Duration(float64(time.Second) / bot.SendRate)
+	if tightUntil, _ := bot.sendTightUntil.Load().(time.Time); time.Now().Before(tightUntil) {
+		interval *= 2
+	}
+	return interval
+}
+
+// noteRateLimited records that Slack rate limited the bot on the RTM
+// connection. The bot then waits at least RateLimitCooldown before
+// reconnecting, and lowers its send rate as noteSendRateLimited does.
+func (bot *SlackBot) noteRateLimited() {
+	cooldown := bot.rateLimitCooldown()
+	bot.logger.Printf("Rate limited by Slack; cooling down for %v\n", cooldown)
+	bot.reconnectNotBefore.Store(time.Now().Add(cooldown))
+	bot.noteSendRateLimited()
+}
+
+// noteSendRateLimited records that Slack rate limited messages sent by the
+// bot. For twice RateLimitCooldown, the bot then sends messages at half of
+// SendRate, or, if AdaptiveSendRate is set, it halves its current send rate.
+func (bot *SlackBot) noteSendRateLimited() {
+	cooldown := bot.rateLimitCooldown()
+	bot.sendTightUntil.Store(time.Now().Add(2 * cooldown))
+	if bot.AdaptiveSendRate && bot.SendRate > 0 {
+		bot.adaptiveRate.halve(bot.SendRate, cooldown)
+	}
+}
+
+// reconnectCooldown returns the time left before the bot may reconnect after
+// being rate limited.
+func (bot *SlackBot) reconnectCooldown() time.Duration {
+	notBefore, _ := bot.reconnectNotBefore.Load().(time.Time)
+	if left := time.Until(notBefore); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// isRateLimitError reports whether an error seen while connecting, or an
+// error message sent by Slack, indicates that the bot was rate limited.
+func isRateLimitError(err error) bool {
+	switch err := err.(type) {
+	case APIError:
+		return err.Code == "ratelimited"
+	case StatusError:
+		return err.StatusCode == 429
+	case RTMError:
+		msg := strings.ToLower(err.Msg)
+		return strings.Contains(msg, "rate limit") || strings.Contains(msg, "ratelimit")
+	}
+	return false
+}
+
+// errorEvent represents an error sent by Slack over the RTM connection that
+// is not a reply to any particular message. Slack may send one, e.g., before
+// disconnecting a bot that sent too many messages.
+// Slack API doc: https://api.slack.com/rtm#errors
+type errorEvent struct {
+	Type  string `json:"type"`
+	Error struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+// EventType returns "error".
+func (event errorEvent) EventType() string { return "error" }
+
+func (event errorEvent) invoke(bot *SlackBot) error {
+	err := RTMError{Code: event.Error.Code, Msg: event.Error.Msg}
+	if isRateLimitError(err) {
+		bot.noteRateLimited()
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package slackbot
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitDisconnectDelaysReconnect(t *testing.T) {
+	bot, api := newTestBot(t)
+	c := newFakeConn()
+	bot.setConn(c)
+	bot.AutoReconnect = true
+	bot.DispatchSync = true
+	bot.ReconnectBaseDelay = time.Millisecond
+	bot.RateLimitCooldown = 200 * time.Millisecond
+	bot.SendRate = 10
+	bot.OnCallbackError = func(err error) {}
+	reconnected := make(chan time.Time, 1)
+	api.handle("rtm.connect", func(call apiCall) interface{} {
+		reconnected <- time.Now()
+		return map[string]interface{}{"ok": true, "url": api.socketURL(), "self": map[string]string{"id": "UBOT", "name": "bot"}}
+	})
+	go bot.listen()
+	defer bot.Disconnect()
+	c.push(`{"type":"error","error":{"code":1,"msg":"Rate limit exceeded"}}`)
+	eventually(t, func() bool { return bot.sendInterval() == 200*time.Millisecond },
+		"send rate not tightened after being rate limited")
+	disconnected := time.Now()
+	c.Close()
+	select {
+	case at := <-reconnected:
+		if delay := at.Sub(disconnected); delay < 150*time.Millisecond {
+			t.Errorf("reconnected after %v, before the cooldown", delay)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+	api.socket(t)
+}
+
+func TestOrdinaryDisconnectReconnectsRightAway(t *testing.T) {
+	bot, api := newTestBot(t)
+	c := newFakeConn()
+	bot.setConn(c)
+	bot.AutoReconnect = true
+	bot.ReconnectBaseDelay = time.Millisecond
+	bot.RateLimitCooldown = time.Second
+	api.serveRTM()
+	go bot.listen()
+	defer bot.Disconnect()
+	disconnected := time.Now()
+	c.Close()
+	api.socket(t)
+	if delay := time.Since(disconnected); delay > 500*time.Millisecond {
+		t.Errorf("reconnected after %v", delay)
+	}
+}
+
+func TestAdaptiveRateRecovers(t *testing.T) {
+	var adaptive adaptiveRate
+	start := time.Now()
+	if rate := adaptive.current(10, time.Second, start); rate != 10 {
+		t.Fatalf("starts at rate %v, want 10", rate)
+	}
+	adaptive.rate, adaptive.at = 5, start
+	for elapsed, want := range map[time.Duration]float64{
+		0:                       5,
+		time.Second:             6,
+		2500 * time.Millisecond: 7.5,
+	} {
+		if rate := adaptive.current(10, time.Second, start.Add(elapsed)); rate < want-1e-9 || rate > want+1e-9 {
+			t.Errorf("rate after %v is %v, want %v", elapsed, rate, want)
+		}
+	}
+	if rate := adaptive.current(10, time.Second, start.Add(6*time.Second)); rate != 10 || adaptive.rate != 0 {
+		t.Errorf("rate after full recovery is %v", rate)
+	}
+	// Repeated halving stops at the minimum rate
+	for i := 0; i < 20; i++ {
+		adaptive.halve(10, time.Hour)
+	}
+	if rate := adaptive.get(10, time.Hour); rate < 10*minAdaptiveRate || rate > 10*minAdaptiveRate*1.01 {
+		t.Errorf("rate after repeated halving is %v, want %v", rate, 10*minAdaptiveRate)
+	}
+}
+
+func TestAdaptiveSendRateOn429(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.SendRate = 10
+	bot.AdaptiveSendRate = true
+	bot.RateLimitCooldown = time.Second
+	limited := true
+	var mutex sync.Mutex
+	api.handle("chat.postMessage", func(call apiCall) interface{} {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if limited {
+			return apiStatus{Code: 429, Header: http.Header{"Retry-After": {"1"}}}
+		}
+		return `{"ok":true,"channel":"C1","ts":"1.5"}`
+	})
+	post := func() error {
+		_, err := bot.PostMessage(PostMessageParameters{Channel: "C1", Text: "hello"})
+		return err
+	}
+	if interval := bot.sendInterval(); interval != 100*time.Millisecond {
+		t.Fatalf("sends every %v before being rate limited", interval)
+	}
+	// Each 429 halves the rate, with little time to recover in between
+	for _, want := range []time.Duration{200 * time.Millisecond, 400 * time.Millisecond} {
+		if err := post(); !isRateLimitError(err) {
+			t.Fatalf("got error %v, want a 429", err)
+		}
+		if interval := bot.sendInterval(); interval < want*3/4 || interval > want {
+			t.Errorf("sends every %v after being rate limited, want about %v", interval, want)
+		}
+	}
+	mutex.Lock()
+	limited = false
+	mutex.Unlock()
+	if err := post(); err != nil {
+		t.Fatal(err)
+	}
+	if interval := bot.sendInterval(); interval < 200*time.Millisecond {
+		t.Errorf("sends every %v right after being rate limited", interval)
+	}
+	// Recover a tenth of SendRate every 10ms rather than every second
+	bot.RateLimitCooldown = 10 * time.Millisecond
+	eventually(t, func() bool { return bot.sendInterval() == 100*time.Millisecond }, "send rate did not recover")
+}
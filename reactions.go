@@ -0,0 +1,71 @@
+package slackbot
+
+import "net/url"
+
+// AddReaction adds the emoji reaction with the given name (e.g. "white_check_mark")
+// to the message with timestamp ts in a given channel. Adding a reaction
+// that the bot has already added is not considered an error.
+// Slack API doc: https://api.slack.com/methods/reactions.add
+func (bot *SlackBot) AddReaction(name, channel, ts string) error {
+	err := bot.callReactionAPI("reactions.add", name, channel, ts)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code == "already_reacted" {
+		return nil
+	}
+	return err
+}
+
+// RemoveReaction removes the bot's emoji reaction with the given name from
+// the message with timestamp ts in a given channel. Removing a reaction that
+// is not present is not considered an error.
+// Slack API doc: https://api.slack.com/methods/reactions.remove
+func (bot *SlackBot) RemoveReaction(name, channel, ts string) error {
+	err := bot.callReactionAPI("reactions.remove", name, channel, ts)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code == "no_reaction" {
+		return nil
+	}
+	return err
+}
+
+func (bot *SlackBot) callReactionAPI(method, name, channel, ts string) error {
+	params := url.Values{
+		"name":      {name},
+		"channel":   {channel},
+		"timestamp": {ts},
+	}
+	var resp apiResponse
+	return bot.callAPI(method, params, &resp)
+}
+
+// Reaction represents an emoji reaction on a message, along with the users
+// who added it.
+type Reaction struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Users []string `json:"users"`
+}
+
+// reactionsGetResponse represents a response sent by the Slack Web API method
+// reactions.get. It is documented at https://api.slack.com/methods/reactions.get
+type reactionsGetResponse struct {
+	apiResponse
+	Message struct {
+		Reactions []Reaction `json:"reactions"`
+	} `json:"message"`
+}
+
+// MessageReactions returns all reactions on the message with timestamp ts
+// in a given channel. Unlike the methods listing items, reactions.get is not
+// paginated: it gives all reactions of the message in one response, and
+// with full set, all users of each reaction.
+func (bot *SlackBot) MessageReactions(channel, ts string) (reactions []Reaction, err error) {
+	params := url.Values{
+		"channel":   {channel},
+		"timestamp": {ts},
+		"full":      {"true"},
+	}
+	var resp reactionsGetResponse
+	if err = bot.callAPI("reactions.get", params, &resp); err != nil {
+		return
+	}
+	return resp.Message.Reactions, nil
+}
@@ -0,0 +1,61 @@
+package slackbot
+
+import "testing"
+
+func TestAddReaction(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("reactions.add", `{"ok":true}`)
+	if err := bot.AddReaction("white_check_mark", "C1", "1.5"); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.callsTo("reactions.add")
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls to reactions.add, want 1", len(calls))
+	}
+	form := calls[0].Form
+	if form.Get("name") != "white_check_mark" || form.Get("channel") != "C1" || form.Get("timestamp") != "1.5" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+	if auth := calls[0].Header.Get("Authorization"); auth != "Bearer xoxb-test" {
+		t.Errorf("authenticated with %q", auth)
+	}
+}
+
+func TestAddReactionAlreadyReacted(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("reactions.add", `{"ok":false,"error":"already_reacted"}`)
+	if err := bot.AddReaction("eyes", "C1", "1.5"); err != nil {
+		t.Errorf("already_reacted gave error %v", err)
+	}
+	api.reply("reactions.add", `{"ok":false,"error":"invalid_name"}`)
+	if err := bot.AddReaction("nope", "C1", "1.5"); err == nil {
+		t.Error("invalid_name gave no error")
+	}
+}
+
+func TestRemoveReaction(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("reactions.remove", `{"ok":false,"error":"no_reaction"}`)
+	if err := bot.RemoveReaction("eyes", "C2", "2.5"); err != nil {
+		t.Errorf("no_reaction gave error %v", err)
+	}
+	if calls := api.callsTo("reactions.remove"); len(calls) != 1 || calls[0].Form.Get("channel") != "C2" {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
+
+func TestMessageReactions(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("reactions.get", `{"ok":true,"type":"message","message":{"text":"hi","reactions":[
+		{"name":"thumbsup","count":2,"users":["U1","U2"]},{"name":"eyes","count":1,"users":["U3"]}]}}`)
+	reactions, err := bot.MessageReactions("C1", "1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reactions) != 2 || reactions[0].Name != "thumbsup" || reactions[0].Count != 2 || len(reactions[0].Users) != 2 {
+		t.Errorf("reactions parsed as %+v", reactions)
+	}
+	if form := api.callsTo("reactions.get")[0].Form; form.Get("full") != "true" || form.Get("timestamp") != "1.5" {
+		t.Errorf("unexpected parameters %v", form)
+	}
+}
@@ -0,0 +1,48 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotReady is returned by SendMessage when Slack did not signal that the
+// connection was ready within ReadyTimeout.
+var ErrNotReady = errors.New("connection not ready")
+
+// markReady records that the current connection is ready, once Slack has
+// sent the hello event on it.
+func (bot *SlackBot) markReady() {
+	bot.readyMutex.Lock()
+	defer bot.readyMutex.Unlock()
+	if bot.ready == nil {
+		return
+	}
+	select {
+	case <-bot.ready:
+	default:
+		close(bot.ready)
+	}
+}
+
+// waitReady waits, if ReadyTimeout is set, until the current connection is
+// ready, the timeout passes, or the context is done. It does not wait if the
+// bot was never connected.
+func (bot *SlackBot) waitReady(ctx context.Context) error {
+	bot.readyMutex.Lock()
+	ready := bot.ready
+	bot.readyMutex.Unlock()
+	if bot.ReadyTimeout <= 0 || ready == nil {
+		return nil
+	}
+	timer := time.NewTimer(bot.ReadyTimeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		return ErrNotReady
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
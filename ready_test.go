@@ -0,0 +1,54 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+// newUnreadyBot returns a bot connected to a fakeConn on which Slack has not
+// yet sent the hello event.
+func newUnreadyBot(t *testing.T) (*SlackBot, *fakeConn) {
+	bot, c := newConnectedBot(t)
+	bot.readyMutex.Lock()
+	bot.ready = make(chan struct{})
+	bot.readyMutex.Unlock()
+	return bot, c
+}
+
+func TestSendWaitsForHello(t *testing.T) {
+	bot, c := newUnreadyBot(t)
+	bot.ReadyTimeout = time.Second
+	sent := make(chan error, 1)
+	go func() { sent <- bot.SendMessage("C1", "early") }()
+	select {
+	case message := <-c.sent:
+		t.Fatalf("sent %s before hello", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+	bot.Dispatch([]byte(`{"type":"hello"}`))
+	if message := c.next(t); message["text"] != "early" {
+		t.Errorf("sent %v", message)
+	}
+	if err := <-sent; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSendGivesUpWithoutHello(t *testing.T) {
+	bot, c := newUnreadyBot(t)
+	bot.ReadyTimeout = 20 * time.Millisecond
+	if err := bot.SendMessage("C1", "early"); err != ErrNotReady {
+		t.Fatalf("got error %v, want ErrNotReady", err)
+	}
+	select {
+	case message := <-c.sent:
+		t.Errorf("sent %s without hello", message)
+	default:
+	}
+	// Without ReadyTimeout, sends do not wait
+	bot.ReadyTimeout = 0
+	if err := bot.SendMessage("C1", "now"); err != nil {
+		t.Fatal(err)
+	}
+	c.next(t)
+}
@@ -0,0 +1,49 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// RecordedEvent is a raw event received from Slack, along with the time
+// at which it was received.
+type RecordedEvent struct {
+	Time time.Time
+	Raw  json.RawMessage
+}
+
+// eventRecorder keeps a fixed number of the most recently received events.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []RecordedEvent // Ring buffer of events, oldest at next once full
+	next   int
+	full   bool
+}
+
+// record adds an event to the recorder, discarding the oldest recorded event
+// if there are already size of them.
+func (recorder *eventRecorder) record(event RecordedEvent, size int) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if len(recorder.events) != size {
+		recorder.events = make([]RecordedEvent, size)
+		recorder.next = 0
+		recorder.full = false
+	}
+	recorder.events[recorder.next] = event
+	recorder.next = (recorder.next + 1) % size
+	recorder.full = recorder.full || recorder.next == 0
+}
+
+// RecentEvents returns the most recently received events, oldest first, up
+// to the number given by RecentEventsSize.
+func (bot *SlackBot) RecentEvents() []RecordedEvent {
+	recorder := &bot.recentEvents
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if !recorder.full {
+		return append([]RecordedEvent(nil), recorder.events[:recorder.next]...)
+	}
+	return append(append([]RecordedEvent(nil), recorder.events[recorder.next:]...), recorder.events[:recorder.next]...)
+}
@@ -0,0 +1,31 @@
+package slackbot
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecentEventsKeepsMostRecent(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.RecentEventsSize = 3
+	if events := bot.RecentEvents(); len(events) != 0 {
+		t.Errorf("got %d events before any were received", len(events))
+	}
+	go bot.listen()
+	for i := 0; i < 5; i++ {
+		c.push(fmt.Sprintf(`{"type":"presence_change","user":"U%d","presence":"away"}`, i))
+	}
+	eventually(t, func() bool {
+		events := bot.RecentEvents()
+		return len(events) == 3 && string(events[2].Raw) == `{"type":"presence_change","user":"U4","presence":"away"}`
+	}, "most recent events not recorded")
+	events := bot.RecentEvents()
+	for i, event := range events {
+		if want := fmt.Sprintf(`{"type":"presence_change","user":"U%d","presence":"away"}`, i+2); string(event.Raw) != want {
+			t.Errorf("event %d is %s, want %s", i, event.Raw, want)
+		}
+		if i > 0 && event.Time.Before(events[i-1].Time) {
+			t.Errorf("events recorded out of order")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package slackbot
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Default delays used between reconnection attempts.
+const (
+	defaultReconnectBaseDelay = time.Second
+	defaultReconnectMaxDelay  = time.Minute
+)
+
+// reconnect closes the current WebSocket connection and tries to open a new
+// one, waiting with exponential backoff between attempts, and for at least
+// RateLimitCooldown if Slack rate limited the bot. If the bot gives up,
+// after ReconnectMaxAttempts attempts or at once on errors that retrying
+// cannot fix, such as a revoked token, OnReconnectFailed is called with the
+// last error seen.
+func (bot *SlackBot) reconnect() (err error) {
+	lost := bot.currentConn()
+	lost.Close()
+	// Only messages written to the lost connection may have gone missing;
+	// those still queued are sent on the new connection as usual
+	unacknowledged := bot.unacknowledged(lost)
+	for attempt := 0; bot.ReconnectMaxAttempts <= 0 || attempt < bot.ReconnectMaxAttempts; attempt++ {
+		delay := bot.reconnectDelay(attempt)
+		if cooldown := bot.reconnectCooldown(); cooldown > delay {
+			delay = cooldown
+		}
+		bot.logger.Printf("Reconnecting in %v (attempt %d)\n", delay, attempt+1)
+		time.Sleep(delay)
+		if bot.isDisconnected() {
+			return errors.New("bot was disconnected while reconnecting")
+		}
+		if err = bot.connect(); err == nil {
+			bot.counters.update(func(counters *counters) { counters.reconnects++ })
+			bot.handleUnacknowledged(unacknowledged)
+			go bot.backfill()
+			return
+		}
+		if bot.isDisconnected() {
+			return errors.New("bot was disconnected while reconnecting")
+		}
+		bot.logger.Println("Reconnection failed:", err)
+		if isRateLimitError(err) {
+			bot.noteRateLimited()
+		} else if !isTemporary(err) {
+			break
+		}
+	}
+	bot.logger.Println("Giving up reconnecting.")
+	for _, message := range unacknowledged {
+		bot.failUnacknowledged(message)
+	}
+	if bot.OnReconnectFailed != nil {
+		bot.OnReconnectFailed(err)
+	}
+	return
+}
+
+// reconnectDelay returns the time to wait before a given reconnection
+// attempt, counting from zero. The delay doubles with each attempt up to
+// ReconnectMaxDelay. With ReconnectJitter, the actual delay is chosen
+// uniformly between zero and that value ("full jitter"), so that many bots
+// disconnected at once do not all reconnect at the same time.
+func (bot *SlackBot) reconnectDelay(attempt int) time.Duration {
+	base := bot.ReconnectBaseDelay
+	if base <= 0 {
+		base = defaultReconnectBaseDelay
+	}
+	max := bot.ReconnectMaxDelay
+	if max <= 0 {
+		max = defaultReconnectMaxDelay
+	}
+	delay := max
+	if attempt < 32 && base<<uint(attempt) > 0 && base<<uint(attempt) < max {
+		delay = base << uint(attempt)
+	}
+	if bot.ReconnectJitter {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+	return delay
+}
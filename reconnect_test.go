@@ -0,0 +1,133 @@
+package slackbot
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestReconnectDelayBounds(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.ReconnectBaseDelay = time.Second
+	bot.ReconnectMaxDelay = 10 * time.Second
+	bot.ReconnectJitter = false
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := bot.reconnectDelay(attempt); got != want {
+			t.Errorf("attempt %d waits %v, want %v", attempt, got, want)
+		}
+	}
+	if got := bot.reconnectDelay(1000); got != bot.ReconnectMaxDelay {
+		t.Errorf("attempt 1000 waits %v, want the maximum", got)
+	}
+	bot.ReconnectJitter = true
+	for attempt := 0; attempt < 10; attempt++ {
+		for i := 0; i < 100; i++ {
+			max := bot.ReconnectBaseDelay << uint(attempt)
+			if max > bot.ReconnectMaxDelay {
+				max = bot.ReconnectMaxDelay
+			}
+			if got := bot.reconnectDelay(attempt); got < 0 || got > max {
+				t.Fatalf("attempt %d waits %v with jitter, want at most %v", attempt, got, max)
+			}
+		}
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	bot, _ := newConnectedBot(t)
+	api := newFakeAPI(t)
+	bot.APIURL = api.server.URL + "/api/"
+	api.reply("rtm.connect", apiStatus{Code: http.StatusServiceUnavailable})
+	bot.ReconnectBaseDelay = time.Millisecond
+	bot.ReconnectMaxAttempts = 3
+	var failure error
+	calls := 0
+	bot.OnReconnectFailed = func(err error) {
+		calls++
+		failure = err
+	}
+	if err := bot.reconnect(); err == nil {
+		t.Error("reconnect succeeded")
+	}
+	if calls != 1 {
+		t.Fatalf("OnReconnectFailed called %d times, want 1", calls)
+	}
+	if statusErr, ok := failure.(StatusError); !ok || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("gave up with %v, want the last error seen", failure)
+	}
+	if attempts := len(api.callsTo("rtm.connect")); attempts != 3 {
+		t.Errorf("made %d attempts, want 3", attempts)
+	}
+}
+
+func TestReconnectGivesUpOnPermanentErrors(t *testing.T) {
+	bot, _ := newConnectedBot(t)
+	api := newFakeAPI(t)
+	bot.APIURL = api.server.URL + "/api/"
+	api.reply("rtm.connect", `{"ok":false,"error":"token_revoked"}`)
+	bot.ReconnectBaseDelay = time.Millisecond
+	var failure error
+	bot.OnReconnectFailed = func(err error) { failure = err }
+	if err := bot.reconnect(); err == nil {
+		t.Error("reconnect succeeded")
+	}
+	if apiErr, ok := failure.(APIError); !ok || apiErr.Code != "token_revoked" {
+		t.Errorf("gave up with %v, want token_revoked", failure)
+	}
+	if attempts := len(api.callsTo("rtm.connect")); attempts != 1 {
+		t.Errorf("made %d attempts, want 1", attempts)
+	}
+}
+
+func TestReconnectAfterDisconnectClosesConnection(t *testing.T) {
+	bot, _ := newConnectedBot(t)
+	if err := bot.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	// A connection dialed while the bot was being disconnected
+	c := newFakeConn()
+	if err := bot.setConn(c); err == nil {
+		t.Error("connection used after disconnecting")
+	}
+	select {
+	case <-c.closed:
+	default:
+		t.Error("connection left open after disconnecting")
+	}
+}
+
+func TestOnReadyOnceAcrossReconnects(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	bot.AutoReconnect = true
+	bot.ReconnectBaseDelay = time.Millisecond
+	var readies, hellos int32
+	bot.OnReady = func(event Hello) error {
+		if atomic.LoadInt32(&hellos) != 0 {
+			t.Error("OnReady called after OnHello")
+		}
+		atomic.AddInt32(&readies, 1)
+		return nil
+	}
+	bot.OnHello = func(event Hello) error {
+		atomic.AddInt32(&hellos, 1)
+		return nil
+	}
+	if err := bot.connect(); err != nil {
+		t.Fatal(err)
+	}
+	go bot.listen()
+	defer bot.Disconnect()
+	for connection := int32(1); connection <= 3; connection++ {
+		ws := api.socket(t)
+		websocket.Message.Send(ws, `{"type":"hello"}`)
+		eventually(t, func() bool { return atomic.LoadInt32(&hellos) == connection }, "OnHello not called on connection %d", connection)
+		ws.Close()
+	}
+	if readies := atomic.LoadInt32(&readies); readies != 1 {
+		t.Errorf("OnReady called %d times, want once", readies)
+	}
+}
@@ -0,0 +1,40 @@
+package slackbot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Replay reads raw events, one per line, from a given reader, and handles
+// each of them in turn as if it had been received from Slack, waiting
+// ReplayDelay between events. Like Dispatch, this is intended for testing
+// bots, e.g. by reproducing a problem from the events recorded through
+// RecentEvents. Empty lines are skipped, and lines that are not valid JSON
+// stop the replay with an error.
+func (bot *SlackBot) Replay(r io.Reader) (err error) {
+	reader := bufio.NewReader(r)
+	replayed := 0
+	for number := 1; ; number++ {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if !json.Valid(line) {
+				return fmt.Errorf("invalid event on line %d", number)
+			}
+			if replayed > 0 && bot.ReplayDelay > 0 {
+				time.Sleep(bot.ReplayDelay)
+			}
+			bot.handleEvent(json.RawMessage(line))
+			replayed++
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
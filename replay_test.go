@@ -0,0 +1,55 @@
+package slackbot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received []string
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event.Text)
+		return nil
+	}
+	bot.OnPresenceChange = func(event PresenceChange) error {
+		received = append(received, event.User+" "+event.Presence)
+		return nil
+	}
+	var log bytes.Buffer
+	log.WriteString(`{"type":"message","channel":"C1","user":"U1","text":"first","ts":"1.0"}` + "\n")
+	log.WriteString(`{"type":"presence_change","user":"U1","presence":"away"}` + "\n\n")
+	log.WriteString(`{"type":"message","channel":"C1","user":"U2","text":"last","ts":"2.0"}`)
+	bot.ReplayDelay = 10 * time.Millisecond
+	start := time.Now()
+	if err := bot.Replay(&log); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("replayed three events in %v, want a delay between each", elapsed)
+	}
+	if want := []string{"first", "U1 away", "last"}; strings.Join(received, ",") != strings.Join(want, ",") {
+		t.Errorf("callbacks got %q, want %q", received, want)
+	}
+}
+
+func TestReplayInvalidEvent(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received int
+	bot.OnMessage = func(event MessageIn) error {
+		received++
+		return nil
+	}
+	log := strings.NewReader(`{"type":"message","channel":"C1","user":"U1","text":"first","ts":"1.0"}
+not an event
+{"type":"message","channel":"C1","user":"U1","text":"never","ts":"2.0"}
+`)
+	if err := bot.Replay(log); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one for line 2", err)
+	}
+	if received != 1 {
+		t.Errorf("replayed %d messages, want only the one before the invalid line", received)
+	}
+}
@@ -0,0 +1,59 @@
+package slackbot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSignatureAge is the largest difference between the timestamp of a signed
+// request and the current time for which the request is accepted.
+const maxSignatureAge = 5 * time.Minute
+
+// maxSignedBodySize is the largest request body read by VerifySignature. It
+// is well above the size of any payload sent by Slack, and only keeps
+// unauthenticated clients from having arbitrarily large bodies buffered.
+const maxSignedBodySize = 1 << 20
+
+// VerifySignature checks that a given request was sent by Slack, by verifying
+// that its X-Slack-Signature header holds a valid signature of the request body
+// made with a given signing secret, using version 0 of Slack's signature scheme.
+// To protect against replay attacks, requests whose X-Slack-Request-Timestamp
+// is more than five minutes away from the current time are rejected, as are
+// requests with bodies larger than 1 MiB. The body of the request remains
+// available for reading afterwards.
+// Slack API doc: https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifySignature(signingSecret string, r *http.Request) (err error) {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return errors.New("request timestamp is too far from the current time")
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return
+	}
+	if len(body) > maxSignedBodySize {
+		return errors.New("request body is too large")
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return errors.New("invalid request signature")
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package slackbot
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := "token=x&team_id=T1&command=%2Fdeploy"
+	r := newSignedRequest("secret", body, time.Now())
+	if err := VerifySignature("secret", r); err != nil {
+		t.Fatal(err)
+	}
+	// The body is still available once verified
+	if read, _ := ioutil.ReadAll(r.Body); string(read) != body {
+		t.Errorf("body read as %q after verification", read)
+	}
+}
+
+func TestVerifySignatureTamperedBody(t *testing.T) {
+	r := newSignedRequest("secret", "amount=1", time.Now())
+	tampered := newSignedRequest("secret", "amount=1000", time.Now())
+	tampered.Header = r.Header
+	if err := VerifySignature("secret", tampered); err == nil {
+		t.Error("tampered body accepted")
+	}
+	if err := VerifySignature("other", newSignedRequest("secret", "amount=1", time.Now())); err == nil {
+		t.Error("wrong secret accepted")
+	}
+}
+
+func TestVerifySignatureExpiredTimestamp(t *testing.T) {
+	for _, at := range []time.Time{time.Now().Add(-10 * time.Minute), time.Now().Add(10 * time.Minute)} {
+		if err := VerifySignature("secret", newSignedRequest("secret", "a=b", at)); err == nil {
+			t.Errorf("request signed at %v accepted", at)
+		}
+	}
+	r := newSignedRequest("secret", "a=b", time.Now())
+	r.Header.Set("X-Slack-Request-Timestamp", "soon")
+	if err := VerifySignature("secret", r); err == nil {
+		t.Error("invalid timestamp accepted")
+	}
+}
+
+func TestVerifySignatureLargeBody(t *testing.T) {
+	body := strings.Repeat("a", maxSignedBodySize)
+	if err := VerifySignature("secret", newSignedRequest("secret", body, time.Now())); err != nil {
+		t.Errorf("body of the largest size rejected: %v", err)
+	}
+	if err := VerifySignature("secret", newSignedRequest("secret", body+"a", time.Now())); err == nil {
+		t.Error("body above the largest size accepted")
+	}
+}
@@ -4,9 +4,12 @@
 package slackbot
 
 import (
+	"crypto/tls"
 	"log"
-
-	"golang.org/x/net/websocket"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // SlackBot represents a single connection of a Slack bot user
@@ -15,33 +18,369 @@ import (
 // be started through a call to Start.
 type SlackBot struct {
 	CallbackErrors chan error // Signals errors seen on user-defined callbacks
-	Done           chan bool  // Signals that the bot has disconnected
+	Done           chan bool  // Signals that the bot has disconnected; buffered, so it need not be read
+
+	// Error delivery. By default, errors returned by callbacks are sent on
+	// CallbackErrors, which must then be read. This may be changed by setting
+	// ErrorPolicy, by replacing CallbackErrors with a buffered channel, or by
+	// providing OnCallbackError, which then receives all errors instead. If
+	// CallbackErrors is set to nil, errors are only logged.
+	ErrorPolicy     ErrorPolicy     // What to do with errors when CallbackErrors is not read
+	OnCallbackError func(err error) // If set, called with callback errors instead of using CallbackErrors
+
+	// CallbackTimeout, if positive, is the time callbacks handling an event
+	// or interaction may take before a CallbackTimeoutError is reported. The
+	// callback is not interrupted, as callbacks take no context, but with
+	// DispatchSync, this makes it visible that a callback is stalling the bot.
+	CallbackTimeout time.Duration
+
+	// Reconnection. If AutoReconnect is set, the bot opens a new connection
+	// when the current one fails, rather than disconnecting. Attempts are
+	// spaced by a delay starting at ReconnectBaseDelay and doubling up to
+	// ReconnectMaxDelay. Separately, ConnectRetries lets Start retry opening
+	// the first connection if it fails for temporary reasons such as network
+	// or server errors; errors such as "invalid_auth" are returned right away.
+	AutoReconnect        bool            // Reconnect when the connection fails
+	ConnectRetries       int             // Times Start retries after temporary failures, with the same delays
+	ReconnectMaxAttempts int             // Attempts before giving up and disconnecting; zero means no limit
+	ReconnectBaseDelay   time.Duration   // Delay before the first attempt
+	ReconnectMaxDelay    time.Duration   // Upper bound on the delay between attempts
+	ReconnectJitter      bool            // Randomize delays to avoid many bots reconnecting at once
+	OnReconnectFailed    func(err error) // Called with the last error when the bot gives up reconnecting
+
+	// SendAckTimeout, if positive, makes SendMessage wait up to this long,
+	// once a message is written to the RTM connection, for Slack to reply,
+	// so that errors reported by Slack, such as the message being too long,
+	// are returned rather than reported like callback errors. If no reply
+	// arrives in time, SendMessage succeeds anyway, unless FailOnAckTimeout
+	// is set, in which case ErrAckTimeout is returned. With DispatchSync, no
+	// replies can be read while a callback runs, so messages sent meanwhile,
+	// e.g. from the callback, are not waited for, and their errors are
+	// reported like callback errors.
+	SendAckTimeout   time.Duration
+	FailOnAckTimeout bool
+
+	// BackfillChannels lists channels, by ID, in which messages posted while
+	// the bot was reconnecting are fetched through the Web API once it has
+	// reconnected, and passed to the callbacks like any other message. Each
+	// message is only handled once, even if also received over the connection.
+	BackfillChannels []string
+
+	// ResendUnacknowledged makes the bot resend, after reconnecting, messages
+	// that Slack had not acknowledged when the connection was lost. As such
+	// messages may have been delivered anyway, they are resent with the same
+	// client_msg_id. By default, they are instead reported as errors of type
+	// UnacknowledgedError, leaving it to the client to decide. Messages not
+	// yet written to the lost connection, for instance per SendRate, are sent
+	// after reconnecting either way.
+	ResendUnacknowledged bool
+
+	// UseRTMStart makes the bot connect through the Web API method rtm.start
+	// rather than rtm.connect. The response of rtm.start includes all users
+	// and channels of the team, which are used to fill the bot's caches, but
+	// it can be very large on big teams, making connecting slow; rtm.connect
+	// only returns what is needed to open the connection.
+	UseRTMStart bool
+
+	// SocketMode makes the bot connect through Socket Mode rather than the
+	// RTM API, which requires an app-level token. This is given in AppToken,
+	// while Start is given the bot token used for the Web API; if AppToken is
+	// empty, Start must be given the app-level token, and the Web API, and
+	// so sending messages, will not be available. Events are delivered to
+	// the same callbacks, and so are interactions and slash commands, which
+	// are otherwise received over HTTP. Socket Mode cannot be used for
+	// sending, so SendMessage posts messages through the Web API.
+	// Slack API doc: https://api.slack.com/apis/connections/socket
+	SocketMode bool
+	AppToken   string // The app-level token, starting with "xapp-", used for opening Socket Mode connections
+
+	// DispatchSync makes the bot handle each event to completion before
+	// reading the next one, rather than handling events concurrently. Events
+	// are then processed strictly in the order they arrive, at the cost of a
+	// slow callback holding up all others.
+	DispatchSync bool
+
+	// AllowedChannels, if not empty, limits the events passed to callbacks
+	// to those in the listed channels, while events in channels listed in
+	// DeniedChannels are never passed on. Channels are given by ID or by
+	// name, and names are matched against the cache, so only channels known
+	// to the bot can be given by name. Interactions and slash commands
+	// received through Socket Mode are filtered by their channel too. Events
+	// not in any channel, such as presence changes, are always passed on.
+	AllowedChannels []string
+	DeniedChannels  []string
+
+	// IgnoredEvents lists types of events, such as "presence_change" or
+	// "user_typing", that are dropped as soon as they are received, without
+	// being parsed or passed to callbacks. They are still counted in Stats.
+	// Ignoring "hello" or "message" breaks the bot.
+	IgnoredEvents []string
+
+	// ReadyTimeout, if positive, makes SendMessage wait up to this long for
+	// Slack to signal that the connection is ready, by sending the hello
+	// event, before sending. Slack may otherwise drop messages sent right
+	// after connecting. If hello does not arrive in time, ErrNotReady is returned.
+	ReadyTimeout time.Duration
+
+	// TLSConfig, if set, is used for all connections to Slack, both to the
+	// Web API and to the WebSocket server, in place of the default system
+	// configuration. This is intended for testing against local servers,
+	// e.g. with InsecureSkipVerify or a custom root CA, and for advanced
+	// setups; most bots should leave it unset.
+	TLSConfig *tls.Config
+
+	// APIURL is the base URL of the Slack Web API, to which method names such
+	// as "chat.postMessage" are appended. It defaults to https://slack.com/api/
+	// and, like TLSConfig, is mainly intended for testing against local servers.
+	APIURL string
+
+	// DialTimeout bounds the time spent opening the WebSocket connection,
+	// including the TLS and WebSocket handshakes, so that an unresponsive
+	// server cannot stall Start. It defaults to 15 seconds; zero means no limit.
+	DialTimeout time.Duration
+
+	// RequiredScopes lists OAuth scopes, such as "chat:write", that the bot
+	// needs. If set, Start fails right away if the token lacks any of them,
+	// rather than the bot failing later on.
+	RequiredScopes []string
+
+	// Extra headers and subprotocols sent when opening the WebSocket
+	// connection, e.g. for authentication or routing at a gateway in front
+	// of Slack. Slack itself needs neither.
+	WebSocketHeader    http.Header
+	WebSocketProtocols []string
+
+	// UserAgent is sent in the User-Agent header of all requests to Slack,
+	// including the WebSocket handshake, unless WebSocketHeader overrides it.
+	// It defaults to identifying this library; if empty, Go's default is used.
+	UserAgent string
+
+	// DedupWindow, if positive, makes the bot remember the given number of
+	// most recent messages and drop any message seen again before invoking
+	// callbacks, such as when Slack redelivers messages after a reconnect.
+	DedupWindow int
+
+	// RecentEventsSize, if positive, makes the bot keep the given number of
+	// the most recently received raw events, available through RecentEvents
+	// for diagnostics.
+	RecentEventsSize int
+
+	// EchoSentMessages makes every message sent by the bot be handled as if it
+	// had also been received from Slack, calling OnMessage and friends. This is
+	// intended for testing bots, and rarely useful otherwise.
+	EchoSentMessages bool
+
+	// AutoMarkRead makes the bot mark messages as read once it has processed
+	// them, which is mostly useful for bots acting as a regular user account.
+	// To limit the number of calls to Slack, marking is delayed by a few
+	// seconds, covering all messages in the channel processed by then.
+	AutoMarkRead bool
+
+	// DryRun makes the bot log and record the messages it sends, available
+	// through SentMessages, instead of actually sending them to Slack. This is
+	// intended for testing bots. OnMessageSent is still called, with an empty
+	// timestamp.
+	DryRun bool
+
+	// ReplayDelay is the time Replay waits between events; zero replays
+	// events as fast as they are handled.
+	ReplayDelay time.Duration
+
+	// DiscardWhilePaused makes the bot drop events received while paused
+	// through Pause, rather than delivering them on Resume.
+	DiscardWhilePaused bool
+
+	// MaxMessageLength is the largest number of characters sent in a single
+	// message by SendMessage; longer messages are split into several, at line
+	// or word boundaries where possible. It defaults to 40000, the limit
+	// imposed by Slack; zero means no limit.
+	MaxMessageLength int
+
+	// ValidateFormatting makes SendMessage, PostMessage and SendMeMessage check
+	// the special tokens, such as mentions, in messages before sending them,
+	// returning a FormattingError rather than sending a message with a
+	// malformed token.
+	ValidateFormatting bool
+
+	// Rate limiting. If SendRate is positive, the bot sends at most that many
+	// messages per second to each channel, whether over the RTM connection or
+	// through the Web API, which is how Slack limits messages; it allows
+	// around one per second over time. Messages to the same channel are
+	// always sent in order, while messages to different channels do not hold
+	// each other up. If Slack rate limits messages anyway, SendRate is halved
+	// for twice RateLimitCooldown, which defaults to a minute, and if it does
+	// so on the RTM connection, reconnection is also delayed by at least
+	// RateLimitCooldown. If AdaptiveSendRate is set, the rate is instead
+	// halved each time messages are rate limited, and then recovers
+	// gradually, by a tenth of SendRate every RateLimitCooldown, keeping busy
+	// bots just under the limit.
+	SendRate          float64       // Messages sent per second; zero means no limit
+	RateLimitCooldown time.Duration // Time to wait before reconnecting after being rate limited
+	AdaptiveSendRate  bool          // Adapt the send rate to rate limiting by Slack
 
 	// Event callbacks. These should be defined by the client and will
 	// be called when the bot encounters the relevant events.
+	OnAppMention     func(event AppMention) error     // The bot was mentioned in a channel, when subscribed through the Events API
 	OnDndUpdatedUser func(event DndUpdatedUser) error // Do not disturb settings changed for a team member
-	OnHello          func(event Hello) error          // The client has successfully connected to the server
-	OnMessage        func(event MessageIn) error      // A message was sent to a channel
-	OnPresenceChange func(event PresenceChange) error // A team member's presence changed
-
-	id           string // The Slack ID of the bot itself
-	name         string // The name identifying the bot on Slack
-	messageID    int32  // Counter to ensure that messages are sent with unique IDs
-	lastPing     int32  // Counter to ensure that pings are sent with unique IDs
-	lastPong     int32  // ID of the last pong message received
-	disconnected bool   // Is true if the WebSocket connection has been closed
-
-	logger *log.Logger     // Logger used for status reports
-	ws     *websocket.Conn // The WebSocket connection on which all communication happens
+
+	OnFileCommentAdded   func(event FileCommentAdded) error   // A comment was added to a file
+	OnFileCommentEdited  func(event FileCommentEdited) error  // A comment on a file was edited
+	OnFileCommentDeleted func(event FileCommentDeleted) error // A comment was deleted from a file
+
+	OnHello           func(event Hello) error          // The client has successfully connected to the server, possibly after reconnecting
+	OnReady           func(event Hello) error          // The client has connected for the first time; called once, before OnHello
+	OnMessage         func(event MessageIn) error      // A message was sent to a channel
+	OnMessageReplied  func(event MessageIn) error      // A reply was posted in a thread; Message holds the updated parent
+	OnThreadBroadcast func(event MessageIn) error      // A thread reply was also sent to the channel; OnMessage is used if unset
+	OnMeMessage       func(event MessageIn) error      // A user posted an action through /me; OnMessage is used if unset
+	OnChannelJoin     func(event MessageIn) error      // User joined Channel; OnMessage is used if unset
+	OnChannelLeave    func(event MessageIn) error      // User left Channel; OnMessage is used if unset
+	OnPresenceChange  func(event PresenceChange) error // A team member's presence changed
+	OnTeamJoin        func(event TeamJoin) error       // A new member joined the team
+	OnUserChange      func(event UserChange) error     // A team member's data changed
+
+	// OnEvent, if set, is called for every event received, before the
+	// callback specific to the type of the event. The event can be
+	// inspected through its EventType, or converted to its specific type,
+	// such as MessageIn, through a type switch.
+	OnEvent func(event Event) error
+
+	// IgnoreOwnMessages makes the bot ignore messages sent by itself, which
+	// keeps it from replying to its own replies.
+	IgnoreOwnMessages bool
+
+	// OnSlashCommand, if set, is called in place of OnMessage for messages
+	// invoking a slash command, such as "/deploy prod", with the command and
+	// the text following it. See MessageIn.SlashCommand.
+	OnSlashCommand func(command, text string, event MessageIn) error
+
+	// Interaction callbacks. These are called for interactions received
+	// through InteractivityHandler rather than over the RTM connection.
+	OnBlockActions       func(interaction InteractionCallback) error // A user interacted with a block element
+	OnInteractiveMessage func(interaction InteractionCallback) error // A user interacted with a legacy message attachment
+
+	// OnMessageSent, if set, is called after every message successfully sent
+	// by the bot, e.g. for auditing, along with the timestamp assigned to it
+	// by Slack. For messages sent over the RTM connection, this happens once
+	// Slack acknowledges the message. For responses sent through RespondURL,
+	// the response URL is given in place of the channel, with no timestamp.
+	OnMessageSent func(channel, text, ts string)
+
+	// OnAck, if set, is called for every reply sent by Slack over the RTM
+	// connection to a message sent by the bot, with the ID of the message,
+	// whether it was accepted, and the timestamp assigned to it, if any. It is
+	// called before the reply is handled otherwise, and should be quick.
+	OnAck func(replyTo int32, ok bool, ts string)
+
+	token         string        // The API token used to authenticate the bot
+	messageID     int32         // Counter to ensure that messages are sent with unique IDs
+	helloOnce     sync.Once     // Ensures that OnReady is only called once
+	readyMutex    sync.Mutex    // Guards ready
+	ready         chan struct{} // Closed once hello is received on the current connection
+	pingMutex     sync.Mutex    // Guards lastPing and lastPong
+	lastPing      int32         // Counter to ensure that pings are sent with unique IDs
+	lastPong      int32         // ID of the last ping answered by a pong
+	lastEventTime atomic.Value  // Time at which the last event was received
+	counters      counters      // Statistics, as returned by Stats
+	dispatching   int32         // Is 1 while listen calls callbacks itself, per DispatchSync
+
+	cacheMutex sync.RWMutex       // Guards the caches below
+	users      map[string]User    // Cache of known users by ID
+	channels   map[string]Channel // Cache of known channels by ID
+
+	emojiMutex sync.Mutex        // Guards emoji
+	emoji      map[string]string // Custom emoji of the workspace, once fetched
+
+	pendingMutex sync.Mutex             // Guards pending
+	pending      map[int32]*pendingSend // Messages sent, or to be sent, over RTM that have not been replied to, by ID
+
+	queueMutex         sync.Mutex               // Guards queues
+	queues             map[string]*channelQueue // Queues of messages waiting to be sent, by channel
+	writes             chan *sendRequest        // Messages ready to be written to the connection
+	writerOnce         sync.Once                // Ensures that only one writer is started
+	writeMutex         sync.Mutex               // Held by the writer while writing a message
+	sendTightUntil     atomic.Value             // Time until which SendRate is halved
+	adaptiveRate       adaptiveRate             // Current send rate, if AdaptiveSendRate is set
+	reconnectNotBefore atomic.Value             // Time before which the bot should not reconnect
+
+	markMutex sync.Mutex        // Guards unmarked
+	unmarked  map[string]string // Latest processed message not yet marked as read, by channel
+
+	dryRunMutex    sync.Mutex    // Guards dryRunMessages
+	dryRunMessages []SentMessage // Messages recorded in dry-run mode
+
+	pauseMutex sync.Mutex // Guards paused and held
+	paused     bool       // Is true if callbacks should not currently be called
+	held       []event    // Events received while paused, in order
+
+	conversationMutex sync.Mutex                // Guards awaiting
+	awaiting          map[string]chan MessageIn // Conversations waiting for an answer, by channel and user
+
+	waiterMutex sync.Mutex            // Guards waiters
+	waiters     map[*eventWaiter]bool // Calls to WaitFor waiting for an event
+
+	threadMutex sync.Mutex                             // Guards followed
+	followed    map[string]func(event MessageIn) error // Handlers of followed threads, by channel and thread timestamp
+
+	dedup        dedupCache    // Recently seen messages, if DedupWindow is set
+	recentEvents eventRecorder // Recently received events, if RecentEventsSize is set
+
+	backfillMutex sync.Mutex        // Guards lastSeen
+	lastSeen      map[string]string // Timestamp of the latest message seen in each channel
+	backfillSeen  dedupCache        // Recently seen messages in BackfillChannels
+
+	httpClientOnce sync.Once    // Ensures that client is only created once
+	client         *http.Client // Client used for requests to the Web API
+
+	logger *log.Logger // Logger used for status reports
+
+	connMutex    sync.Mutex // Guards the fields below, which change when reconnecting
+	ws           conn       // The WebSocket connection on which all communication happens
+	disconnected bool       // Is true if the WebSocket connection has been closed
+	id           string     // The Slack ID of the bot itself
+	name         string     // The name identifying the bot on Slack
 }
 
+// Default values of SlackBot options.
+const (
+	defaultDialTimeout      = 15 * time.Second
+	defaultMaxMessageLength = 40000
+	defaultUserAgent        = "slackbot-go (+https://github.com/fuglede/slackbot)"
+)
+
 // New creates a new SlackBot with a predefined logger.
 func New(logger *log.Logger) *SlackBot {
 	return &SlackBot{
-		CallbackErrors: make(chan error),
-		Done:           make(chan bool),
-		logger:         logger,
-		messageID:      0,
-		disconnected:   false,
+		APIURL:             defaultAPIURL,
+		CallbackErrors:     make(chan error),
+		Done:               make(chan bool, 1),
+		DialTimeout:        defaultDialTimeout,
+		MaxMessageLength:   defaultMaxMessageLength,
+		RateLimitCooldown:  defaultRateLimitCooldown,
+		ReconnectBaseDelay: defaultReconnectBaseDelay,
+		ReconnectMaxDelay:  defaultReconnectMaxDelay,
+		ReconnectJitter:    true,
+		UserAgent:          defaultUserAgent,
+		logger:             logger,
+		messageID:          0,
+		disconnected:       false,
 	}
 }
+
+// selfID returns the Slack ID of the bot, if known.
+func (bot *SlackBot) selfID() string {
+	bot.connMutex.Lock()
+	defer bot.connMutex.Unlock()
+	return bot.id
+}
+
+// SetIdentity sets the Slack ID and name of the bot, which are otherwise
+// only known once the bot has connected. This allows features relying on the
+// identity, such as IgnoreOwnMessages, to be used with Dispatch in tests. The
+// identity is replaced by the actual one when the bot connects.
+func (bot *SlackBot) SetIdentity(id, name string) {
+	bot.connMutex.Lock()
+	defer bot.connMutex.Unlock()
+	bot.id = id
+	bot.name = name
+}
@@ -0,0 +1,290 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// apiCall is a request received by a fakeAPI.
+type apiCall struct {
+	Method string
+	Header http.Header
+	Query  url.Values // The parameters in the URL
+	Form   url.Values // The form parameters, for methods called with a form
+	Body   []byte     // The raw body, e.g. for methods called with JSON
+}
+
+// apiStatus is a response of a fakeAPI with a given HTTP status code and
+// headers, and a raw body, if any.
+type apiStatus struct {
+	Code   int
+	Header http.Header
+	Body   string
+}
+
+// fakeAPI is a local server standing in for the Slack Web API. Methods are
+// answered by handlers returning a response, which is sent as JSON unless
+// it is a string, in which case it is sent as is, or an apiStatus.
+type fakeAPI struct {
+	server  *httptest.Server
+	sockets chan *websocket.Conn // WebSocket connections opened by bots at socketURL
+	stop    chan struct{}
+
+	mutex    sync.Mutex
+	handlers map[string]func(call apiCall) interface{}
+	calls    []apiCall
+}
+
+// newFakeAPI starts a fakeAPI, which is stopped when the test ends.
+func newFakeAPI(t *testing.T) *fakeAPI {
+	return startFakeAPI(t, httptest.NewServer)
+}
+
+// newFakeTLSAPI starts a fakeAPI served over TLS with a self-signed
+// certificate, which is stopped when the test ends.
+func newFakeTLSAPI(t *testing.T) *fakeAPI {
+	return startFakeAPI(t, httptest.NewTLSServer)
+}
+
+func startFakeAPI(t *testing.T, start func(handler http.Handler) *httptest.Server) *fakeAPI {
+	api := &fakeAPI{
+		handlers: make(map[string]func(call apiCall) interface{}),
+		sockets:  make(chan *websocket.Conn, 10),
+		stop:     make(chan struct{}),
+	}
+	api.server = start(http.HandlerFunc(api.serveHTTP))
+	t.Cleanup(api.server.Close)
+	t.Cleanup(func() { close(api.stop) })
+	return api
+}
+
+func (api *fakeAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ws" {
+		websocket.Handler(api.serveWebSocket).ServeHTTP(w, r)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	call := apiCall{Method: strings.TrimPrefix(r.URL.Path, "/api/"), Header: r.Header, Query: r.URL.Query(), Body: body}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		call.Form, _ = url.ParseQuery(string(body))
+	}
+	api.mutex.Lock()
+	api.calls = append(api.calls, call)
+	handler, ok := api.handlers[call.Method]
+	api.mutex.Unlock()
+	if !ok {
+		w.Write([]byte(`{"ok":false,"error":"unknown_method"}`))
+		return
+	}
+	switch response := handler(call).(type) {
+	case apiStatus:
+		for key, values := range response.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(response.Code)
+		w.Write([]byte(response.Body))
+	case string:
+		w.Write([]byte(response))
+	default:
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// serveWebSocket makes a new connection available on sockets, and keeps it
+// open until the test ends or it is closed.
+func (api *fakeAPI) serveWebSocket(ws *websocket.Conn) {
+	api.sockets <- ws
+	<-api.stop
+}
+
+// socketURL returns the URL at which the fakeAPI accepts WebSocket connections.
+func (api *fakeAPI) socketURL() string {
+	return "ws" + strings.TrimPrefix(api.server.URL, "http") + "/ws"
+}
+
+// serveRTM makes rtm.connect succeed, pointing bots to socketURL.
+func (api *fakeAPI) serveRTM() {
+	api.reply("rtm.connect", map[string]interface{}{
+		"ok":   true,
+		"url":  api.socketURL(),
+		"self": map[string]string{"id": "UBOT", "name": "bot"},
+	})
+}
+
+// socket returns the next WebSocket connection opened by a bot, failing the
+// test if none is opened within a second.
+func (api *fakeAPI) socket(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case ws := <-api.sockets:
+		return ws
+	case <-time.After(time.Second):
+		t.Fatal("no WebSocket connection opened")
+		return nil
+	}
+}
+
+// handle sets the handler of a given method.
+func (api *fakeAPI) handle(method string, handler func(call apiCall) interface{}) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.handlers[method] = handler
+}
+
+// reply makes a given method always answer with a given response.
+func (api *fakeAPI) reply(method string, response interface{}) {
+	api.handle(method, func(apiCall) interface{} { return response })
+}
+
+// callsTo returns the calls received to a given method, oldest first.
+func (api *fakeAPI) callsTo(method string) (calls []apiCall) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	for _, call := range api.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return
+}
+
+// newTestLogger returns a logger discarding everything.
+func newTestLogger() *log.Logger {
+	return log.New(ioutil.Discard, "", 0)
+}
+
+// newTestBot returns a bot whose Web API calls go to a new fakeAPI.
+func newTestBot(t *testing.T) (*SlackBot, *fakeAPI) {
+	api := newFakeAPI(t)
+	bot := New(newTestLogger())
+	bot.APIURL = api.server.URL + "/api/"
+	bot.token = "xoxb-test"
+	return bot, api
+}
+
+// fakeConn is a conn standing in for the WebSocket connection to Slack.
+// Messages sent by the bot are available on sent, and messages pushed to
+// incoming are received by the bot.
+type fakeConn struct {
+	incoming  chan json.RawMessage
+	sent      chan json.RawMessage
+	gate      chan struct{} // If not nil, each Send waits for a value on gate
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newFakeConn returns a new fakeConn.
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		incoming: make(chan json.RawMessage, 100),
+		sent:     make(chan json.RawMessage, 100),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) Send(v interface{}) error {
+	if c.gate != nil {
+		select {
+		case <-c.gate:
+		case <-c.closed:
+		}
+	}
+	select {
+	case <-c.closed:
+		return errors.New("connection closed")
+	default:
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.sent <- data
+	return nil
+}
+
+func (c *fakeConn) Receive(v interface{}) error {
+	select {
+	case data := <-c.incoming:
+		return json.Unmarshal(data, v)
+	case <-c.closed:
+		return io.EOF
+	}
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// push makes the bot receive a given raw message.
+func (c *fakeConn) push(raw string) {
+	c.incoming <- json.RawMessage(raw)
+}
+
+// next returns the next message sent by the bot, failing the test if none
+// is sent within a second.
+func (c *fakeConn) next(t *testing.T) (message map[string]interface{}) {
+	t.Helper()
+	select {
+	case data := <-c.sent:
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatal(err)
+		}
+		return
+	case <-time.After(time.Second):
+		t.Fatal("no message sent")
+		return
+	}
+}
+
+// newConnectedBot returns a bot using a new fakeConn, as if it had connected.
+// The bot does not read from the connection unless listen is started.
+func newConnectedBot(t *testing.T) (*SlackBot, *fakeConn) {
+	bot, _ := newTestBot(t)
+	c := newFakeConn()
+	bot.setConn(c)
+	bot.SetIdentity("UBOT", "bot")
+	t.Cleanup(func() { c.Close() })
+	return bot, c
+}
+
+// eventually fails the test if a given condition does not become true
+// within a second.
+func eventually(t *testing.T, condition func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSetIdentityFiltersOwnMessages(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.SetIdentity("UBOT", "bot")
+	bot.IgnoreOwnMessages = true
+	var received []string
+	bot.OnMessage = func(event MessageIn) error {
+		received = append(received, event.User)
+		return nil
+	}
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"UBOT","text":"from myself"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"from someone else"}`))
+	if len(received) != 1 || received[0] != "U1" {
+		t.Errorf("OnMessage called for messages from %v", received)
+	}
+}
@@ -0,0 +1,171 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// envelope represents a message received over a Socket Mode connection.
+// All messages other than hello and disconnect carry an envelope ID and
+// must be acknowledged, or Slack will send them again.
+// Slack API doc: https://api.slack.com/apis/connections/socket-implement
+type envelope struct {
+	Type         string          `json:"type"` // E.g. "events_api", "interactive", or "slash_commands"
+	EnvelopeID   string          `json:"envelope_id"`
+	Payload      json.RawMessage `json:"payload"`
+	RetryAttempt int             `json:"retry_attempt"` // Zero unless Slack delivered the envelope before
+	RetryReason  string          `json:"retry_reason"`  // E.g. "timeout"
+	Reason       string          `json:"reason"`        // For disconnect messages, e.g. "refresh_requested"
+}
+
+// envelopeAck represents the acknowledgement of an envelope.
+type envelopeAck struct {
+	EnvelopeID string `json:"envelope_id"`
+}
+
+// eventsAPIPayload represents the payload of an events_api envelope.
+// Slack API doc: https://api.slack.com/apis/connections/events-api#callback-field
+type eventsAPIPayload struct {
+	Type  string          `json:"type"` // Always "event_callback"
+	Event json.RawMessage `json:"event"`
+}
+
+// slashCommandPayload represents the payload of a slash_commands envelope.
+// Slack API doc: https://api.slack.com/interactivity/slash-commands#app_command_handling
+type slashCommandPayload struct {
+	Command   string `json:"command"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// slashCommand is a slash command received through Socket Mode, handled like
+// an event so that it is held while the bot is paused and filtered by
+// channel. It is passed to no callbacks other than OnSlashCommand, not even
+// OnEvent.
+type slashCommand struct {
+	payload slashCommandPayload
+}
+
+// EventType returns "slash_commands".
+func (event slashCommand) EventType() string { return "slash_commands" }
+
+func (event slashCommand) invoke(bot *SlackBot) error {
+	if bot.OnSlashCommand == nil {
+		return nil
+	}
+	message := MessageIn{
+		Type:    "message",
+		Channel: event.payload.ChannelID,
+		User:    event.payload.UserID,
+		Text:    strings.TrimSpace(event.payload.Command + " " + event.payload.Text),
+	}
+	return bot.OnSlashCommand(event.payload.Command, event.payload.Text, message)
+}
+
+// openSocketModeConnection gets a URL for opening a Socket Mode connection
+// through the Web API method apps.connections.open, authenticating with the
+// app-level token, and the bot's identity through auth.test if the bot token
+// is available.
+// Slack API doc: https://api.slack.com/methods/apps.connections.open
+func (bot *SlackBot) openSocketModeConnection() (msg connectMessage, err error) {
+	bot.logger.Println("Getting Socket Mode URL from Slack web API")
+	req, err := http.NewRequest("POST", bot.methodURL("apps.connections.open"), nil)
+	if err != nil {
+		return
+	}
+	if bot.AppToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bot.AppToken)
+	}
+	if err = bot.doAPIRequest("apps.connections.open", req, &msg); err != nil || bot.AppToken == "" {
+		return
+	}
+	info, err := bot.AuthTest()
+	if err != nil {
+		return
+	}
+	msg.Self.ID = info.UserID
+	msg.Self.Name = info.User
+	return
+}
+
+// acknowledge parses a message received over a Socket Mode connection and
+// acknowledges it if it is an envelope needing acknowledgement.
+func (bot *SlackBot) acknowledge(rawEnvelope json.RawMessage) (envelope envelope) {
+	if err := json.Unmarshal(rawEnvelope, &envelope); err != nil || envelope.EnvelopeID == "" {
+		return
+	}
+	if err := bot.currentConn().Send(envelopeAck{EnvelopeID: envelope.EnvelopeID}); err != nil {
+		bot.logger.Println("Failed to acknowledge envelope:", err)
+	}
+	return
+}
+
+// handleEnvelope calls the relevant callbacks for a given message received
+// over a Socket Mode connection, once acknowledged. Events are handled like
+// those received over RTM, except that retried messages are flagged through
+// MessageIn.RetryAttempt. Slash commands are passed to OnSlashCommand along
+// with a message holding the command as it would have been typed.
+// Interactions and slash commands are also subject to channel filters and
+// Pause, but are passed to no other callbacks, not even OnEvent.
+func (bot *SlackBot) handleEnvelope(envelope envelope, rawEnvelope json.RawMessage) {
+	switch envelope.Type {
+	case "hello":
+		bot.handleEvent(rawEnvelope)
+	case "disconnect":
+		// Closing the connection makes listen reconnect if AutoReconnect is set
+		bot.logger.Println("Slack asked to disconnect: " + envelope.Reason)
+		bot.currentConn().Close()
+	case "events_api":
+		var payload eventsAPIPayload
+		if json.Unmarshal(envelope.Payload, &payload) != nil {
+			return
+		}
+		eventType := rawEventType(payload.Event)
+		if eventType == "" {
+			return
+		}
+		if bot.eventIgnored(eventType) {
+			bot.counters.countEvent(eventType)
+			return
+		}
+		bot.logger.Println("Received event: " + string(payload.Event))
+		bot.counters.countEvent(eventType)
+		event, exists := decodeEvent(eventType, payload.Event)
+		if !exists || !bot.eventAllowed(event) {
+			return
+		}
+		if message, ok := event.(*MessageIn); ok {
+			message.RetryAttempt = envelope.RetryAttempt
+			message.RetryReason = envelope.RetryReason
+		}
+		if bot.hold(event) {
+			return
+		}
+		bot.dispatch(event)
+	case "interactive":
+		var callback InteractionCallback
+		if json.Unmarshal(envelope.Payload, &callback) != nil {
+			return
+		}
+		bot.logger.Println("Received interaction: " + callback.Type)
+		bot.handleCallbackOnly(&interaction{callback})
+	case "slash_commands":
+		var payload slashCommandPayload
+		if json.Unmarshal(envelope.Payload, &payload) != nil {
+			return
+		}
+		bot.logger.Println("Received slash command: " + payload.Command)
+		bot.handleCallbackOnly(&slashCommand{payload})
+	}
+}
+
+// handleCallbackOnly passes on an interaction or slash command received
+// through Socket Mode like an event, subject to channel filters and Pause.
+func (bot *SlackBot) handleCallbackOnly(event event) {
+	if !bot.eventAllowed(event) || bot.hold(event) {
+		return
+	}
+	bot.dispatch(event)
+}
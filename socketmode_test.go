@@ -0,0 +1,214 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// startSocketModeBot starts a bot in Socket Mode against a fakeAPI, and
+// returns the server side of its connection.
+func startSocketModeBot(t *testing.T, bot *SlackBot, api *fakeAPI) *websocket.Conn {
+	t.Helper()
+	bot.SocketMode = true
+	bot.AppToken = "xapp-test"
+	api.reply("apps.connections.open", map[string]interface{}{"ok": true, "url": api.socketURL()})
+	api.reply("auth.test", `{"ok":true,"user":"bot","user_id":"UBOT"}`)
+	if err := bot.Start("xoxb-test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.Disconnect() })
+	ws := api.socket(t)
+	websocket.Message.Send(ws, `{"type":"hello","num_connections":1}`)
+	return ws
+}
+
+// receiveAck returns the envelope ID of the next acknowledgement sent by the
+// bot, failing the test if none arrives within a second.
+func receiveAck(t *testing.T, ws *websocket.Conn) string {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var ack envelopeAck
+	if err := websocket.JSON.Receive(ws, &ack); err != nil {
+		t.Fatal("no acknowledgement received:", err)
+	}
+	return ack.EnvelopeID
+}
+
+func TestSocketModeDispatchesEvents(t *testing.T) {
+	bot, api := newTestBot(t)
+	messages := make(chan MessageIn, 1)
+	bot.OnMessage = func(event MessageIn) error {
+		messages <- event
+		return nil
+	}
+	ws := startSocketModeBot(t, bot, api)
+	if auth := api.callsTo("apps.connections.open")[0].Header.Get("Authorization"); auth != "Bearer xapp-test" {
+		t.Errorf("opened the connection with %q, want the app token", auth)
+	}
+	if bot.selfID() != "UBOT" {
+		t.Errorf("bot identified as %q", bot.selfID())
+	}
+	websocket.Message.Send(ws, `{"type":"events_api","envelope_id":"E1","payload":{"type":"event_callback",
+		"event":{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0"}}}`)
+	if id := receiveAck(t, ws); id != "E1" {
+		t.Errorf("acknowledged envelope %q, want E1", id)
+	}
+	select {
+	case message := <-messages:
+		if message.Text != "hi" || message.Channel != "C1" || message.RetryAttempt != 0 {
+			t.Errorf("message parsed as %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnMessage not called")
+	}
+}
+
+func TestSocketModeSendsThroughWebAPI(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.postMessage", `{"ok":true,"channel":"C1","ts":"1.5"}`)
+	startSocketModeBot(t, bot, api)
+	if err := bot.SendMessage("C1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.callsTo("chat.postMessage"); len(calls) != 1 {
+		t.Errorf("got %d calls to chat.postMessage, want 1", len(calls))
+	}
+}
+
+func TestSocketModeAcksBeforeCallbacks(t *testing.T) {
+	bot, api := newTestBot(t)
+	release := make(chan struct{})
+	messages := make(chan MessageIn, 2)
+	bot.OnMessage = func(event MessageIn) error {
+		<-release
+		messages <- event
+		return nil
+	}
+	bot.DispatchSync = true
+	ws := startSocketModeBot(t, bot, api)
+	defer close(release)
+	websocket.Message.Send(ws, `{"type":"events_api","envelope_id":"E1","payload":{"type":"event_callback",
+		"event":{"type":"message","channel":"C1","user":"U1","text":"slow","ts":"1.0"}}}`)
+	websocket.Message.Send(ws, `{"type":"events_api","envelope_id":"E2","retry_attempt":1,"retry_reason":"timeout",
+		"payload":{"type":"event_callback","event":{"type":"message","channel":"C1","user":"U1","text":"retried","ts":"2.0"}}}`)
+	// The first envelope is acknowledged while its callback is still blocked
+	if id := receiveAck(t, ws); id != "E1" {
+		t.Errorf("acknowledged envelope %q, want E1", id)
+	}
+	release <- struct{}{}
+	if id := receiveAck(t, ws); id != "E2" {
+		t.Errorf("acknowledged envelope %q, want E2", id)
+	}
+	release <- struct{}{}
+	first, retried := <-messages, <-messages
+	if first.RetryAttempt != 0 || first.RetryReason != "" {
+		t.Errorf("first delivery flagged as a retry: %+v", first)
+	}
+	if retried.RetryAttempt != 1 || retried.RetryReason != "timeout" {
+		t.Errorf("retried delivery not flagged: %+v", retried)
+	}
+}
+
+func TestSocketModeTokens(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.reply("chat.postMessage", `{"ok":true,"channel":"C1","ts":"1.5"}`)
+	startSocketModeBot(t, bot, api)
+	if _, err := bot.PostMessage(PostMessageParameters{Channel: "C1", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	for method, want := range map[string]string{
+		"apps.connections.open": "Bearer xapp-test",
+		"auth.test":             "Bearer xoxb-test",
+		"chat.postMessage":      "Bearer xoxb-test",
+	} {
+		calls := api.callsTo(method)
+		if len(calls) == 0 {
+			t.Errorf("%s not called", method)
+			continue
+		}
+		if auth := calls[0].Header.Get("Authorization"); auth != want {
+			t.Errorf("%s called with %q, want %q", method, auth, want)
+		}
+	}
+}
+
+func TestSocketModeEventsWithoutTypeNotCounted(t *testing.T) {
+	bot := New(newTestLogger())
+	bot.IgnoredEvents = []string{"user_typing"}
+	for _, event := range []string{`{"text":"no type"}`, `{"type":"user_typing"}`, `{"type":"presence_change"}`} {
+		payload := `{"type":"event_callback","event":` + event + `}`
+		bot.handleEnvelope(envelope{Type: "events_api", Payload: []byte(payload)}, nil)
+	}
+	events := bot.Stats().Events
+	if len(events) != 2 || events["user_typing"] != 1 || events["presence_change"] != 1 {
+		t.Errorf("counted events %v", events)
+	}
+}
+
+func TestSocketModeCommandsPausedAndFiltered(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.DeniedChannels = []string{"C2"}
+	bot.DispatchSync = true
+	received := make(chan string, 10)
+	bot.OnSlashCommand = func(command, text string, event MessageIn) error {
+		received <- command + " in " + event.Channel
+		return nil
+	}
+	bot.OnBlockActions = func(interaction InteractionCallback) error {
+		received <- interaction.Actions[0].ActionID + " in " + interaction.Channel.ID
+		return nil
+	}
+	bot.OnEvent = func(event Event) error {
+		if event.EventType() != "hello" {
+			received <- "OnEvent " + event.EventType()
+		}
+		return nil
+	}
+	hello := make(chan struct{})
+	bot.OnHello = func(event Hello) error {
+		close(hello)
+		return nil
+	}
+	ws := startSocketModeBot(t, bot, api)
+	<-hello
+	bot.Pause()
+	websocket.Message.Send(ws, `{"type":"slash_commands","envelope_id":"E1","payload":{"command":"/deploy","text":"now",
+		"user_id":"U1","channel_id":"C1"}}`)
+	websocket.Message.Send(ws, `{"type":"slash_commands","envelope_id":"E2","payload":{"command":"/deploy","text":"now",
+		"user_id":"U1","channel_id":"C2"}}`)
+	websocket.Message.Send(ws, `{"type":"interactive","envelope_id":"E3","payload":{"type":"block_actions",
+		"channel":{"id":"C1"},"actions":[{"action_id":"approve"}]}}`)
+	for _, want := range []string{"E1", "E2", "E3"} {
+		if id := receiveAck(t, ws); id != want {
+			t.Errorf("acknowledged envelope %q, want %s", id, want)
+		}
+	}
+	eventually(t, func() bool {
+		bot.pauseMutex.Lock()
+		defer bot.pauseMutex.Unlock()
+		return len(bot.held) == 2
+	}, "commands not held while paused")
+	select {
+	case got := <-received:
+		t.Fatalf("%s while paused", got)
+	default:
+	}
+	bot.Resume()
+	for _, want := range []string{"/deploy in C1", "approve in C1"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s not received after resuming", want)
+		}
+	}
+	select {
+	case got := <-received:
+		t.Errorf("got %s, want nothing more", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,60 @@
+package slackbot
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// File represents a file shared in Slack.
+// Slack API doc: https://api.slack.com/types/file
+type File struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	User  string `json:"user"`
+}
+
+// Item represents a starred item, which is either a message, a file, or a
+// channel, as given by Type.
+type Item struct {
+	Type        string        `json:"type"` // One of "message", "file", "channel", "im", or "group"
+	Channel     string        `json:"channel"`
+	Message     *MessageIn    `json:"message"`
+	File        *File         `json:"file"`
+	DateCreated UnixTimestamp `json:"date_create"`
+}
+
+// starsListResponse represents a response sent by the Slack Web API method
+// stars.list. It is documented at https://api.slack.com/methods/stars.list
+type starsListResponse struct {
+	apiResponse
+	Items            []Item           `json:"items"`
+	ResponseMetadata responseMetadata `json:"response_metadata"`
+}
+
+// starsPageSize is the number of items requested per page from stars.list.
+const starsPageSize = 200
+
+// Stars returns all items starred by the user owning the bot's token,
+// following pagination as needed. Slack does not allow listing the stars
+// of other users.
+func (bot *SlackBot) Stars() (items []Item, err error) {
+	params := url.Values{"limit": {strconv.Itoa(starsPageSize)}}
+	for {
+		var resp starsListResponse
+		if err = bot.callAPI("stars.list", params, &resp); err != nil {
+			return
+		}
+		for _, item := range resp.Items {
+			if item.Message != nil && item.Message.Channel == "" {
+				item.Message.Channel = item.Channel
+			}
+			items = append(items, item)
+		}
+		cursor := resp.ResponseMetadata.NextCursor
+		if cursor == "" {
+			return
+		}
+		params.Set("cursor", cursor)
+	}
+}
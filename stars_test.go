@@ -0,0 +1,34 @@
+package slackbot
+
+import "testing"
+
+func TestStarsPagination(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.handle("stars.list", func(call apiCall) interface{} {
+		if call.Form.Get("cursor") == "" {
+			return `{"ok":true,"items":[{"type":"message","channel":"C1","message":{"text":"starred","ts":"1.0"},"date_create":1500000000},
+				{"type":"file","file":{"id":"F1","name":"report.pdf"}}],"response_metadata":{"next_cursor":"page2"}}`
+		}
+		return `{"ok":true,"items":[{"type":"channel","channel":"C2"}],"response_metadata":{"next_cursor":""}}`
+	})
+	items, err := bot.Stars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	if message := items[0].Message; message == nil || message.Text != "starred" || message.Channel != "C1" || items[0].DateCreated != 1500000000 {
+		t.Errorf("message item parsed as %+v", items[0])
+	}
+	if file := items[1].File; file == nil || file.ID != "F1" {
+		t.Errorf("file item parsed as %+v", items[1])
+	}
+	if items[2].Type != "channel" || items[2].Channel != "C2" {
+		t.Errorf("channel item parsed as %+v", items[2])
+	}
+	calls := api.callsTo("stars.list")
+	if len(calls) != 2 || calls[1].Form.Get("cursor") != "page2" {
+		t.Errorf("unexpected requests %+v", calls)
+	}
+}
@@ -1,73 +1,133 @@
 package slackbot
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io/ioutil"
-	"net/http"
+	"net/url"
+	"reflect"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/websocket"
 )
 
 // Start opens a WebSocket connection to Slack and starts listening
-// for messages.
+// for messages. If RequiredScopes is set, the scopes of the token are
+// checked first, and a MissingScopesError is returned if any are missing.
+// A bot that has been disconnected may be started again.
 func (bot *SlackBot) Start(token string) (err error) {
-	msg, err := bot.getConnectionInformation(token)
-	if err != nil {
-		return
+	bot.token = token
+	bot.connMutex.Lock()
+	bot.disconnected = false
+	bot.connMutex.Unlock()
+	if len(bot.RequiredScopes) > 0 {
+		if err = bot.checkScopes(); err != nil {
+			return
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		err = bot.connect()
+		if err == nil || attempt >= bot.ConnectRetries || !isTemporary(err) {
+			break
+		}
+		delay := bot.reconnectDelay(attempt)
+		bot.logger.Printf("Connecting failed: %v; retrying in %v\n", err, delay)
+		time.Sleep(delay)
 	}
-	bot.id = msg.Self.ID
-	bot.name = msg.Self.Name
-	bot.ws, err = websocket.Dial(msg.URL, "", "https://api.slack.com/")
 	if err != nil {
 		return
 	}
-	bot.logger.Println("Connected. Listening for events.")
 	go bot.listen()
-	// The standard Go WebSocket library does not support WebSocket pings,
-	// but Slack provides a custom heartbeat mechanism that we use here instead
-	go bot.sendPings()
 	return
 }
 
-// getConnectionInformation performs the initial call to the Slack HTTP API,
-// which gets us the bot's ID and name, as well as a URL for opening a
-// WebSocket connection.
-func (bot SlackBot) getConnectionInformation(token string) (msg connectMessage, err error) {
-	url := "https://slack.com/api/rtm.connect?token=" + token
-	bot.logger.Println("Getting websocket URL from Slack web API")
-	resp, err := http.Get(url)
+// connect gets the connection information for the bot, opens a new
+// WebSocket connection, and starts sending pings on it.
+func (bot *SlackBot) connect() (err error) {
+	msg, err := bot.getConnectionInformation()
 	if err != nil {
 		return
 	}
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("API websocket URL request failed with code %d", resp.StatusCode)
+	bot.SetIdentity(msg.Self.ID, msg.Self.Name)
+	if bot.UseRTMStart && !bot.SocketMode {
+		channels := append(append(msg.Channels, msg.Groups...), msg.IMs...)
+		bot.fillCaches(msg.Users, channels)
+	}
+	config, err := websocket.NewConfig(msg.URL, "https://api.slack.com/")
+	if err != nil {
 		return
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
+	config.TlsConfig = bot.TLSConfig
+	config.Protocol = bot.WebSocketProtocols
+	if bot.UserAgent != "" {
+		config.Header.Set("User-Agent", bot.UserAgent)
+	}
+	for key, values := range bot.WebSocketHeader {
+		config.Header[key] = values
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if bot.DialTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, bot.DialTimeout)
+	}
+	dialed, err := config.DialContext(ctx)
+	cancel()
 	if err != nil {
 		return
 	}
-	if err = json.Unmarshal(body, &msg); err != nil {
+	ws := &wsConn{ws: dialed, codec: bot.codec()}
+	if err = bot.setConn(ws); err != nil {
 		return
 	}
+	bot.pingMutex.Lock()
+	bot.lastPing = 0
+	bot.lastPong = 0
+	bot.pingMutex.Unlock()
+	bot.readyMutex.Lock()
+	bot.ready = make(chan struct{})
+	bot.readyMutex.Unlock()
+	bot.counters.update(func(counters *counters) { counters.connectedSince = time.Now() })
+	bot.logger.Println("Connected. Listening for events.")
+	// The standard Go WebSocket library does not support WebSocket pings,
+	// but Slack provides a custom heartbeat mechanism that we use here instead.
+	// In Socket Mode, Slack sends WebSocket pings, which the library answers.
+	if !bot.SocketMode {
+		go bot.sendPings(ws)
+	}
+	return
+}
 
-	if !msg.Ok {
-		err = fmt.Errorf("Slack error: %s", msg.Error)
+// getConnectionInformation performs the initial call to the Slack HTTP API,
+// which gets us the bot's ID and name, as well as a URL for opening a
+// WebSocket connection. If UseRTMStart is set, rtm.start is used in place
+// of rtm.connect, and the response also includes the team's users and channels.
+// If SocketMode is set, apps.connections.open is called with the AppToken, if
+// any, and as its response only includes the URL, the bot's identity is then
+// found through auth.test.
+// As with all Web API calls, the token is sent in a header rather than in the
+// URL, so that it cannot end up in logs or error messages.
+func (bot *SlackBot) getConnectionInformation() (msg connectMessage, err error) {
+	if bot.SocketMode {
+		return bot.openSocketModeConnection()
 	}
+	method := "rtm.connect"
+	if bot.UseRTMStart {
+		method = "rtm.start"
+	}
+	bot.logger.Println("Getting websocket URL from Slack web API")
+	err = bot.callAPI(method, url.Values{}, &msg)
 	return
 }
 
-// connectMessage represents a response sent by the Slack Web API method
-// rtm.connect. It is documented at https://api.slack.com/methods/rtm.connect
+// connectMessage represents a response sent by the Slack Web API methods
+// rtm.connect and rtm.start. They are documented at https://api.slack.com/methods/rtm.connect
+// and https://api.slack.com/methods/rtm.start; only the latter includes the
+// fields following Self.
 type connectMessage struct {
-	Ok    bool   `json:"ok"`
-	Error string `json:"error"`
-	URL   string `json:"url"`
-	Team  struct {
+	apiResponse
+	URL  string `json:"url"`
+	Team struct {
 		ID             string `json:"id"`
 		Name           string `json:"name"`
 		Domain         string `json:"domain"`
@@ -78,35 +138,81 @@ type connectMessage struct {
 		ID   string `json:"id"`
 		Name string `json:"name"`
 	} `json:"self"`
+	Users    []User    `json:"users"`
+	Channels []Channel `json:"channels"`
+	Groups   []Channel `json:"groups"`
+	IMs      []Channel `json:"ims"`
 }
 
 // listen will continuously parse messages from the bot's RTM connection
-// and spawn handlers for each of them. It disconnects the bot if any
-// errors occur.
+// and spawn handlers for each of them, or handle them in turn if
+// DispatchSync is set. If any errors occur, it reconnects
+// if AutoReconnect is set, and otherwise disconnects the bot.
 func (bot *SlackBot) listen() (err error) {
+	ws := bot.currentConn()
 	for {
 		event := json.RawMessage{}
-		err = websocket.JSON.Receive(bot.ws, &event)
+		err = ws.Receive(&event)
 		if err != nil {
+			// The bot may also have been started again since it was
+			// disconnected, in which case the new connection is not ours
+			if bot.isDisconnected() || bot.currentConn() != ws {
+				return
+			}
 			bot.logger.Print("Error receiving JSON from websocket :", err)
+			if bot.AutoReconnect && bot.reconnect() == nil {
+				ws = bot.currentConn()
+				continue
+			}
 			break
 		}
-		go bot.handleEvent(event)
+		now := time.Now()
+		bot.lastEventTime.Store(now)
+		if bot.RecentEventsSize > 0 {
+			bot.recentEvents.record(RecordedEvent{Time: now, Raw: event}, bot.RecentEventsSize)
+		}
+		handle := func() { bot.handleEvent(event) }
+		if bot.SocketMode {
+			// Acknowledge envelopes right away, rather than after handling
+			// earlier ones, as Slack resends them after three seconds
+			envelope := bot.acknowledge(event)
+			handle = func() { bot.handleEnvelope(envelope, event) }
+		}
+		if bot.DispatchSync {
+			atomic.StoreInt32(&bot.dispatching, 1)
+			handle()
+			atomic.StoreInt32(&bot.dispatching, 0)
+		} else {
+			go handle()
+		}
 	}
 	bot.Disconnect()
 	return
 }
 
 // Disconnect closes the WebSocket connection and signals completion
-// on the Done channel.
-func (bot SlackBot) Disconnect() error {
-	if !bot.disconnected {
-		bot.logger.Println("Disconnecting.")
-		bot.Done <- true
-		bot.disconnected = true
-		return bot.ws.Close()
+// on the Done channel. The signal is buffered, so Disconnect does not
+// block if no one is reading from Done. Disconnecting a bot that was
+// never started returns an error and has no effect.
+func (bot *SlackBot) Disconnect() error {
+	bot.connMutex.Lock()
+	if bot.ws == nil {
+		bot.connMutex.Unlock()
+		return errors.New("bot is not connected")
+	}
+	if bot.disconnected {
+		bot.connMutex.Unlock()
+		return errors.New("bot is already disconnected")
+	}
+	bot.disconnected = true
+	ws := bot.ws
+	bot.connMutex.Unlock()
+	bot.logger.Println("Disconnecting.")
+	select {
+	case bot.Done <- true:
+	default:
 	}
-	return errors.New("bot is already disconnected")
+	return ws.Close()
 }
 
 // typeOnlyEvent represents a generic message received from the Slack RTM API. It
@@ -115,36 +221,153 @@ type typeOnlyEvent struct {
 	Type string `json:"type"`
 }
 
+// rawEventType returns the type of a raw event. As Slack sends the type as
+// the first field of its events, it is read without decoding the rest of the
+// event where possible, so that events are only decoded in full once.
+func rawEventType(rawEvent json.RawMessage) string {
+	rest := rawEvent
+	for _, expected := range []string{"{", `"type"`, ":", `"`} {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		if !bytes.HasPrefix(rest, []byte(expected)) {
+			rest = nil
+			break
+		}
+		rest = rest[len(expected):]
+	}
+	// Types never need escaping, so give up on the fast path if they do
+	if end := bytes.IndexAny(rest, `"\`); end > 0 && rest[end] == '"' {
+		return string(rest[:end])
+	}
+	var firstPassEvent typeOnlyEvent
+	json.Unmarshal(rawEvent, &firstPassEvent)
+	return firstPassEvent.Type
+}
+
+// Dispatch handles a given raw event as if it had been received from Slack,
+// calling the relevant callbacks before returning. This is intended for
+// testing bots without connecting them.
+func (bot *SlackBot) Dispatch(rawEvent []byte) {
+	bot.handleEvent(json.RawMessage(rawEvent))
+}
+
 // handleEvent parses a general Slack event into its specific type
 // and calls the relevant callbacks.
 func (bot *SlackBot) handleEvent(rawEvent json.RawMessage) {
 	// We unmarshal in two steps. First, we get the type of the event.
-	var firstPassEvent typeOnlyEvent
-	json.Unmarshal(rawEvent, &firstPassEvent)
+	eventType := rawEventType(rawEvent)
+	if eventType != "" && bot.eventIgnored(eventType) {
+		bot.counters.countEvent(eventType)
+		return
+	}
 	bot.logger.Println("Received event: " + string(rawEvent))
+	// Replies to messages sent by the bot carry no type
+	if eventType == "" {
+		bot.handleReply(rawEvent)
+		return
+	}
+	bot.counters.countEvent(eventType)
+	if eventType == "hello" {
+		bot.markReady()
+	}
 	// Now we have the type and can unmarshal into that type
-	event, exists := makeEventByType(firstPassEvent.Type)
+	event, exists := decodeEvent(eventType, rawEvent)
+	if !exists || !bot.eventAllowed(event) {
+		return
+	}
+	if bot.hold(event) {
+		return
+	}
+	bot.dispatch(event)
+}
+
+// eventIgnored reports whether events of a given type are listed in IgnoredEvents.
+func (bot *SlackBot) eventIgnored(eventType string) bool {
+	for _, ignored := range bot.IgnoredEvents {
+		if ignored == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeEvent unmarshals a raw event into a new event of a given type, if
+// the type is supported and the event is valid JSON.
+func decodeEvent(eventType string, rawEvent json.RawMessage) (event event, exists bool) {
+	event, exists = makeEventByType(eventType)
 	if !exists {
 		return
 	}
-	json.Unmarshal(rawEvent, &event)
+	// Unmarshal into the new event itself rather than the interface holding
+	// it, so that each event is always decoded into an instance of its own.
+	// Fields of unexpected types are left empty, as before.
+	if _, invalid := json.Unmarshal(rawEvent, event).(*json.SyntaxError); invalid {
+		return nil, false
+	}
+	return
+}
+
+// dispatch calls the callbacks for a given event and reports any errors.
+func (bot *SlackBot) dispatch(event event) {
+	if bot.dropped(event) {
+		return
+	}
+	defer bot.watchCallback(event.EventType())()
+	if !isInternal(event) && !isCallbackOnly(event) {
+		// Pass on the event itself rather than the pointer it was
+		// unmarshalled into, as for the specific callbacks
+		value := reflect.ValueOf(event).Elem().Interface().(Event)
+		bot.notifyWaiters(value)
+		if bot.OnEvent != nil {
+			if err := bot.OnEvent(value); err != nil {
+				bot.reportError(err)
+			}
+		}
+	}
 	err := event.invoke(bot)
 	if err != nil {
-		bot.CallbackErrors <- err
+		bot.reportError(err)
+	}
+}
+
+// dropped reports whether a given event should be passed to no callbacks at
+// all, including OnEvent and WaitFor, which is the case for messages already
+// handled according to DedupWindow or when backfilling, for the bot's own
+// messages if IgnoreOwnMessages is set, and for answers taken by a call to
+// Conversation.Ask.
+func (bot *SlackBot) dropped(event event) bool {
+	message, ok := event.(*MessageIn)
+	if !ok {
+		return false
+	}
+	if bot.isDuplicateMessage(*message) || bot.noteMessage(*message) {
+		return true
 	}
+	if bot.IgnoreOwnMessages && message.User != "" && message.User == bot.selfID() {
+		return true
+	}
+	return bot.answer(*message)
 }
 
-// sendPings sends a ping every minute, ensures that pongs are returned,
-// and disconnects when they are not.
-func (bot *SlackBot) sendPings() (err error) {
+// sendPings sends a ping every minute on a given connection, ensures that
+// pongs are returned, and closes the connection when they are not. It stops
+// once the bot has moved on to another connection.
+func (bot *SlackBot) sendPings(ws conn) (err error) {
 	for {
-		// If more than three minutes passed since the last pong, disconnect.
+		if bot.isDisconnected() || bot.currentConn() != ws {
+			return
+		}
+		// If more than three minutes passed since the last pong, close the
+		// connection, leaving it to listen to reconnect or disconnect.
+		bot.pingMutex.Lock()
 		if bot.lastPing-bot.lastPong > 2 {
-			return bot.Disconnect()
+			bot.pingMutex.Unlock()
+			bot.logger.Println("No pongs received; closing connection.")
+			return ws.Close()
 		}
 		bot.lastPing++
 		pingMessage := pingMessage{ID: bot.lastPing, Type: "ping"}
-		websocket.JSON.Send(bot.ws, pingMessage)
+		bot.pingMutex.Unlock()
+		ws.Send(pingMessage)
 		time.Sleep(time.Minute)
 	}
 }
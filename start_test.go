@@ -0,0 +1,286 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestDisconnectWithoutDoneReader(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	returned := make(chan error, 1)
+	go func() { returned <- bot.Disconnect() }()
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect blocked with no one reading Done")
+	}
+	select {
+	case <-c.closed:
+	default:
+		t.Error("connection not closed")
+	}
+	if err := bot.Disconnect(); err == nil {
+		t.Error("disconnecting twice gave no error")
+	}
+	select {
+	case <-bot.Done:
+	default:
+		t.Error("Done not signalled")
+	}
+}
+
+func TestStartAfterDisconnect(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	for i := 0; i < 2; i++ {
+		if err := bot.Start("xoxb-test"); err != nil {
+			t.Fatal(err)
+		}
+		ws := api.socket(t)
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var ping pingMessage
+		if err := websocket.JSON.Receive(ws, &ping); err != nil || ping.Type != "ping" {
+			t.Fatalf("no ping sent after start %d: %v", i+1, err)
+		}
+		if err := bot.Disconnect(); err != nil {
+			t.Fatalf("disconnecting after start %d: %v", i+1, err)
+		}
+		var message string
+		if err := websocket.Message.Receive(ws, &message); err != io.EOF {
+			t.Fatalf("connection of start %d not closed: %v", i+1, err)
+		}
+		<-bot.Done
+	}
+}
+
+func TestDisconnectUnstartedBot(t *testing.T) {
+	bot := New(newTestLogger())
+	if err := bot.Disconnect(); err == nil {
+		t.Error("disconnecting a bot that was never started gave no error")
+	}
+}
+
+func TestDispatchSyncKeepsOrder(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.DispatchSync = true
+	var mutex sync.Mutex
+	var received []string
+	bot.OnMessage = func(event MessageIn) error {
+		// Earlier events taking longer must still be handled first
+		if event.Text == "0" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, event.Text)
+		return nil
+	}
+	for i := 0; i < 50; i++ {
+		c.push(fmt.Sprintf(`{"type":"message","channel":"C1","user":"U1","text":"%d"}`, i))
+	}
+	go bot.listen()
+	eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received) == 50
+	}, "not all events handled")
+	for i, text := range received {
+		if text != strconv.Itoa(i) {
+			t.Fatalf("events handled in order %v", received)
+		}
+	}
+}
+
+func TestStartRetriesServerErrors(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.ConnectRetries = 3
+	bot.ReconnectBaseDelay = time.Millisecond
+	api.handle("rtm.connect", func(call apiCall) interface{} {
+		if len(api.callsTo("rtm.connect")) == 1 {
+			return apiStatus{Code: http.StatusInternalServerError}
+		}
+		return map[string]interface{}{"ok": true, "url": api.socketURL(), "self": map[string]string{"id": "UBOT", "name": "bot"}}
+	})
+	if err := bot.Start("xoxb-test"); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Disconnect()
+	api.socket(t)
+	if attempts := len(api.callsTo("rtm.connect")); attempts != 2 {
+		t.Errorf("connected after %d attempts, want 2", attempts)
+	}
+}
+
+func TestStartFailsFastOnInvalidAuth(t *testing.T) {
+	bot, api := newTestBot(t)
+	bot.ConnectRetries = 3
+	bot.ReconnectBaseDelay = time.Millisecond
+	api.reply("rtm.connect", `{"ok":false,"error":"invalid_auth"}`)
+	err := bot.Start("xoxb-test")
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code != "invalid_auth" {
+		t.Errorf("got error %v, want invalid_auth", err)
+	}
+	if attempts := len(api.callsTo("rtm.connect")); attempts != 1 {
+		t.Errorf("made %d attempts, want 1", attempts)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	// A listener accepting connections but never completing the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			<-stop
+			conn.Close()
+		}
+	}()
+	bot, api := newTestBot(t)
+	bot.DialTimeout = 100 * time.Millisecond
+	api.reply("rtm.connect", map[string]interface{}{"ok": true, "url": "ws://" + listener.Addr().String() + "/ws"})
+	start := time.Now()
+	if err := bot.connect(); err == nil {
+		t.Fatal("connected to a listener that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial failed after %v, want about %v", elapsed, bot.DialTimeout)
+	}
+}
+
+func TestWebSocketHandshakeHeaders(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	bot.WebSocketHeader = http.Header{"X-Gateway-Route": {"eu-1"}}
+	bot.WebSocketProtocols = []string{"slack-rtm"}
+	bot.UserAgent = "testbot/1.0"
+	if err := bot.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.currentConn().Close()
+	ws := api.socket(t)
+	header := ws.Request().Header
+	if header.Get("X-Gateway-Route") != "eu-1" || header.Get("User-Agent") != "testbot/1.0" {
+		t.Errorf("handshake sent with headers %v", header)
+	}
+	if protocols := ws.Config().Protocol; len(protocols) != 1 || protocols[0] != "slack-rtm" {
+		t.Errorf("handshake offered protocols %v", protocols)
+	}
+}
+
+func TestPongsMustMatchOutstandingPings(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	go bot.sendPings(c)
+	if ping := c.next(t); ping["type"] != "ping" || ping["id"] != 1.0 {
+		t.Fatalf("sent %v, want the first ping", ping)
+	}
+	for _, replyTo := range []int{0, 2, 99} {
+		bot.Dispatch([]byte(fmt.Sprintf(`{"type":"pong","reply_to":%d}`, replyTo)))
+	}
+	bot.pingMutex.Lock()
+	lastPong := bot.lastPong
+	bot.pingMutex.Unlock()
+	if lastPong != 0 || !bot.Stats().LastPong.IsZero() {
+		t.Fatalf("pong for an unknown ping counted as liveness")
+	}
+	bot.Dispatch([]byte(`{"type":"pong","reply_to":1}`))
+	bot.pingMutex.Lock()
+	lastPong = bot.lastPong
+	bot.pingMutex.Unlock()
+	if lastPong != 1 || bot.Stats().LastPong.IsZero() {
+		t.Errorf("pong for the outstanding ping not counted")
+	}
+}
+
+func TestRawEventType(t *testing.T) {
+	for _, test := range []struct{ raw, want string }{
+		{`{"type":"message","text":"hi"}`, "message"},
+		{"{ \"type\" :\n \"hello\"}", "hello"},
+		{`{"text":"hi","type":"message"}`, "message"},
+		{`{"type":"message"}`, "message"},
+		{`{"ok":true,"reply_to":1}`, ""},
+		{`not json`, ""},
+	} {
+		if got := rawEventType(json.RawMessage(test.raw)); got != test.want {
+			t.Errorf("type of %s read as %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+// BenchmarkHandleEvent measures the handling of a typical message, and
+// compares decoding it after reading its type from the raw event with
+// decoding it after first decoding its type separately, as was done before.
+func BenchmarkHandleEvent(b *testing.B) {
+	rawEvent := json.RawMessage(`{"type":"message","channel":"C2147483705","user":"U2147483697",
+		"text":"Hello world, this is a fairly typical message with <@U2147483697> and a link <https://example.com|example>",
+		"ts":"1355517523.000005","client_msg_id":"4c2a3f1e-7d0b-4e8a-9f6c-2b1d5e8a7c3f","team":"T12345678",
+		"blocks":[{"type":"rich_text","block_id":"abc","elements":[]}],"event_ts":"1355517523.000005"}`)
+	b.Run("Handle", func(b *testing.B) {
+		bot := New(newTestLogger())
+		bot.DispatchSync = true
+		bot.OnMessage = func(event MessageIn) error { return nil }
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bot.handleEvent(rawEvent)
+		}
+	})
+	b.Run("DecodeTwoPass", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var firstPassEvent typeOnlyEvent
+			json.Unmarshal(rawEvent, &firstPassEvent)
+			decodeEvent(firstPassEvent.Type, rawEvent)
+		}
+	})
+	b.Run("DecodeRawType", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decodeEvent(rawEventType(rawEvent), rawEvent)
+		}
+	})
+}
+
+func TestIgnoredEvents(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.IgnoredEvents = []string{"presence_change", "user_typing"}
+	var dispatched []string
+	bot.OnEvent = func(event Event) error {
+		dispatched = append(dispatched, event.EventType())
+		return nil
+	}
+	bot.OnPresenceChange = func(event PresenceChange) error {
+		t.Error("OnPresenceChange called for an ignored event")
+		return nil
+	}
+	bot.CallbackErrors = make(chan error, 10)
+	bot.Dispatch([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	bot.Dispatch([]byte(`{"type":"user_typing","channel":"C1","user":"U1"}`))
+	// Ignored events are dropped before the rest of them is read
+	bot.Dispatch([]byte(`{"type":"presence_change","user":["not", "a", "string"`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.0"}`))
+	if len(dispatched) != 1 || dispatched[0] != "message" {
+		t.Errorf("dispatched %v, want only the message", dispatched)
+	}
+	if len(bot.CallbackErrors) != 0 {
+		t.Errorf("ignored events gave error %v", <-bot.CallbackErrors)
+	}
+	if counted := bot.Stats().Events["presence_change"]; counted != 2 {
+		t.Errorf("counted %d presence_change events, want 2", counted)
+	}
+}
@@ -0,0 +1,147 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// LastEventTime returns the time at which the bot last received an event
+// of any kind from Slack, or the zero time if none have been received.
+func (bot *SlackBot) LastEventTime() time.Time {
+	t, _ := bot.lastEventTime.Load().(time.Time)
+	return t
+}
+
+// ConnectedSince returns the time at which the current connection to Slack
+// was opened, or the zero time if the bot has not connected yet.
+func (bot *SlackBot) ConnectedSince() time.Time {
+	bot.counters.mutex.Lock()
+	defer bot.counters.mutex.Unlock()
+	return bot.counters.connectedSince
+}
+
+// BytesSent returns the number of bytes of JSON sent by the bot over the RTM
+// connection, across reconnections, not counting WebSocket framing.
+func (bot *SlackBot) BytesSent() int64 {
+	bot.counters.mutex.Lock()
+	defer bot.counters.mutex.Unlock()
+	return bot.counters.bytesSent
+}
+
+// BytesReceived returns the number of bytes of JSON received by the bot over the
+// RTM connection, across reconnections, not counting WebSocket framing.
+func (bot *SlackBot) BytesReceived() int64 {
+	bot.counters.mutex.Lock()
+	defer bot.counters.mutex.Unlock()
+	return bot.counters.bytesReceived
+}
+
+// PendingSends returns the number of messages waiting to be sent, whether
+// held back by SendRate or waiting for the writer of the RTM connection.
+func (bot *SlackBot) PendingSends() int {
+	bot.counters.mutex.Lock()
+	defer bot.counters.mutex.Unlock()
+	return bot.counters.pending
+}
+
+// TotalSent returns the number of messages sent by the bot and accepted by
+// Slack, across reconnections, whether sent over the RTM connection or
+// through the Web API.
+func (bot *SlackBot) TotalSent() int64 {
+	bot.counters.mutex.Lock()
+	defer bot.counters.mutex.Unlock()
+	return bot.counters.sent
+}
+
+// BotStats is a snapshot of the activity of a bot, as returned by Stats.
+// Counts are across reconnections.
+type BotStats struct {
+	ConnectedSince time.Time        // When the current connection was opened
+	LastPong       time.Time        // When Slack last answered a ping on the RTM connection
+	Reconnects     int64            // Successful reconnections
+	Events         map[string]int64 // Events received, by type, including unsupported types
+	MessagesSent   int64            // As given by TotalSent
+	SendErrors     int64            // Messages that could not be sent or were rejected by Slack
+	PendingSends   int              // As given by PendingSends
+	BytesSent      int64            // As given by BytesSent
+	BytesReceived  int64            // As given by BytesReceived
+}
+
+// Stats returns a snapshot of the activity of the bot, e.g. for exposing
+// metrics. All values are taken at the same instant.
+func (bot *SlackBot) Stats() BotStats {
+	counters := &bot.counters
+	counters.mutex.Lock()
+	defer counters.mutex.Unlock()
+	events := make(map[string]int64, len(counters.events))
+	for eventType, count := range counters.events {
+		events[eventType] = count
+	}
+	return BotStats{
+		ConnectedSince: counters.connectedSince,
+		LastPong:       counters.lastPong,
+		Reconnects:     counters.reconnects,
+		Events:         events,
+		MessagesSent:   counters.sent,
+		SendErrors:     counters.sendErrors,
+		PendingSends:   counters.pending,
+		BytesSent:      counters.bytesSent,
+		BytesReceived:  counters.bytesReceived,
+	}
+}
+
+// counters holds the statistics of a bot, guarded by a single mutex so
+// that they can be read consistently.
+type counters struct {
+	mutex          sync.Mutex
+	connectedSince time.Time
+	lastPong       time.Time
+	reconnects     int64
+	events         map[string]int64
+	pending        int
+	sent           int64
+	sendErrors     int64
+	bytesSent      int64
+	bytesReceived  int64
+}
+
+// update calls a given function updating the counters while holding the mutex.
+func (stats *counters) update(f func(counters *counters)) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	f(stats)
+}
+
+// countEvent counts an event of a given type.
+func (stats *counters) countEvent(eventType string) {
+	stats.update(func(counters *counters) {
+		if counters.events == nil {
+			counters.events = make(map[string]int64)
+		}
+		counters.events[eventType]++
+	})
+}
+
+// addPending adds a given number, which may be negative, to the pending messages.
+func (stats *counters) addPending(n int) {
+	stats.update(func(counters *counters) { counters.pending += n })
+}
+
+// codec returns a WebSocket codec that works like websocket.JSON, but also
+// counts the bytes sent and received.
+func (bot *SlackBot) codec() websocket.Codec {
+	return websocket.Codec{
+		Marshal: func(v interface{}) (data []byte, payloadType byte, err error) {
+			data, err = json.Marshal(v)
+			bot.counters.update(func(counters *counters) { counters.bytesSent += int64(len(data)) })
+			return data, websocket.TextFrame, err
+		},
+		Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+			bot.counters.update(func(counters *counters) { counters.bytesReceived += int64(len(data)) })
+			return json.Unmarshal(data, v)
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestLastEventTimeAdvances(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	if !bot.LastEventTime().IsZero() {
+		t.Error("LastEventTime set before any event")
+	}
+	go bot.listen()
+	start := time.Now()
+	c.push(`{"type":"hello"}`)
+	eventually(t, func() bool { return !bot.LastEventTime().IsZero() }, "LastEventTime not set")
+	first := bot.LastEventTime()
+	if first.Before(start) {
+		t.Errorf("LastEventTime %v before the event was sent at %v", first, start)
+	}
+	time.Sleep(time.Millisecond)
+	c.push(`{"type":"presence_change","user":"U1","presence":"away"}`)
+	eventually(t, func() bool { return bot.LastEventTime().After(first) }, "LastEventTime did not advance")
+}
+
+func TestConnectedSinceAndByteCounts(t *testing.T) {
+	bot, api := newTestBot(t)
+	api.serveRTM()
+	bot.ReconnectBaseDelay = time.Millisecond
+	if !bot.ConnectedSince().IsZero() {
+		t.Error("ConnectedSince set before connecting")
+	}
+	before := time.Now()
+	if err := bot.connect(); err != nil {
+		t.Fatal(err)
+	}
+	ws := api.socket(t)
+	first := bot.ConnectedSince()
+	if first.Before(before) || first.After(time.Now()) {
+		t.Errorf("ConnectedSince is %v, want the time of connecting", first)
+	}
+	websocket.Message.Send(ws, `{"type":"hello"}`)
+	var event json.RawMessage
+	if err := bot.currentConn().Receive(&event); err != nil {
+		t.Fatal(err)
+	}
+	if received := bot.BytesReceived(); received != int64(len(`{"type":"hello"}`)) {
+		t.Errorf("counted %d bytes received", received)
+	}
+	if err := bot.currentConn().Send(pingMessage{ID: 42, Type: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	if bot.BytesSent() == 0 {
+		t.Error("bytes sent not counted")
+	}
+	time.Sleep(time.Millisecond)
+	if err := bot.reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.currentConn().Close()
+	api.socket(t)
+	if !bot.ConnectedSince().After(first) {
+		t.Errorf("ConnectedSince not reset on reconnecting")
+	}
+	if bot.Stats().Reconnects != 1 {
+		t.Errorf("counted %d reconnects, want 1", bot.Stats().Reconnects)
+	}
+}
+
+func TestPendingSendsWithBlockedWriter(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	c.gate = make(chan struct{})
+	for i := 0; i < 4; i++ {
+		bot.SendAsync("C1", fmt.Sprint("message ", i))
+	}
+	// The writer holds the first message while Send blocks
+	eventually(t, func() bool { return bot.PendingSends() == 3 }, "pending sends never reached 3")
+	if stats := bot.Stats(); stats.PendingSends != 3 {
+		t.Errorf("Stats reports %d sends pending", stats.PendingSends)
+	}
+	for i := 0; i < 4; i++ {
+		c.gate <- struct{}{}
+		bot.Dispatch([]byte(fmt.Sprintf(`{"ok":true,"reply_to":%v,"ts":"1.%d"}`, c.next(t)["id"], i)))
+	}
+	if pending := bot.PendingSends(); pending != 0 {
+		t.Errorf("%d sends pending after the writer caught up", pending)
+	}
+	if sent := bot.TotalSent(); sent != 4 {
+		t.Errorf("TotalSent is %d, want 4", sent)
+	}
+}
+
+func TestStatsReflectActivity(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.OnCallbackError = func(err error) {}
+	bot.Dispatch([]byte(`{"type":"hello"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"one","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"two","ts":"2.0"}`))
+	bot.Dispatch([]byte(`{"type":"not_yet_supported"}`))
+	for _, text := range []string{"accepted", "rejected"} {
+		if err := bot.SendMessage("C1", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bot.Dispatch([]byte(fmt.Sprintf(`{"ok":true,"reply_to":%v,"ts":"3.0"}`, c.next(t)["id"])))
+	bot.Dispatch([]byte(fmt.Sprintf(`{"ok":false,"reply_to":%v,"error":{"code":2,"msg":"message text is missing"}}`, c.next(t)["id"])))
+	stats := bot.Stats()
+	if stats.Events["hello"] != 1 || stats.Events["message"] != 2 || stats.Events["not_yet_supported"] != 1 {
+		t.Errorf("counted events %v", stats.Events)
+	}
+	if stats.MessagesSent != 1 || stats.SendErrors != 1 || stats.PendingSends != 0 {
+		t.Errorf("counted %d messages sent, %d send errors, and %d pending, want 1, 1, and 0",
+			stats.MessagesSent, stats.SendErrors, stats.PendingSends)
+	}
+	// The snapshot does not change with later activity
+	bot.Dispatch([]byte(`{"type":"hello"}`))
+	if stats.Events["hello"] != 1 || bot.Stats().Events["hello"] != 2 {
+		t.Error("snapshot shares its event counts with the bot")
+	}
+}
@@ -0,0 +1,34 @@
+package slackbot
+
+// FollowThread routes subsequent replies in the thread of a given channel
+// whose parent message has timestamp threadTs to a given handler, rather
+// than to OnMessage, until the thread is unfollowed through UnfollowThread.
+// Following a thread again replaces its handler.
+func (bot *SlackBot) FollowThread(channel, threadTs string, handler func(event MessageIn) error) {
+	bot.threadMutex.Lock()
+	defer bot.threadMutex.Unlock()
+	if bot.followed == nil {
+		bot.followed = make(map[string]func(event MessageIn) error)
+	}
+	bot.followed[channel+"/"+threadTs] = handler
+}
+
+// UnfollowThread stops routing replies in a thread followed through
+// FollowThread to its handler.
+func (bot *SlackBot) UnfollowThread(channel, threadTs string) {
+	bot.threadMutex.Lock()
+	defer bot.threadMutex.Unlock()
+	delete(bot.followed, channel+"/"+threadTs)
+}
+
+// threadHandler returns the handler of the followed thread that a given
+// message is a reply in, if any.
+func (bot *SlackBot) threadHandler(event MessageIn) (handler func(event MessageIn) error, ok bool) {
+	if !event.IsThreadReply() {
+		return
+	}
+	bot.threadMutex.Lock()
+	defer bot.threadMutex.Unlock()
+	handler, ok = bot.followed[event.Channel+"/"+event.ThreadTs]
+	return
+}
@@ -0,0 +1,31 @@
+package slackbot
+
+import "testing"
+
+func TestFollowThread(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var followed, messages []string
+	bot.OnMessage = func(event MessageIn) error {
+		messages = append(messages, event.Text)
+		return nil
+	}
+	bot.FollowThread("C1", "1.0", func(event MessageIn) error {
+		followed = append(followed, event.Text)
+		return nil
+	})
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"parent","ts":"1.0","thread_ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"reply","ts":"2.0","thread_ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C2","user":"U1","text":"elsewhere","ts":"3.0","thread_ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"other thread","ts":"4.0","thread_ts":"0.5"}`))
+	if len(followed) != 1 || followed[0] != "reply" {
+		t.Errorf("thread handler got %q", followed)
+	}
+	if len(messages) != 3 {
+		t.Errorf("OnMessage got %q", messages)
+	}
+	bot.UnfollowThread("C1", "1.0")
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"later","ts":"5.0","thread_ts":"1.0"}`))
+	if len(followed) != 1 || len(messages) != 4 || messages[3] != "later" {
+		t.Errorf("reply after unfollowing routed to thread handler %q or OnMessage %q", followed, messages)
+	}
+}
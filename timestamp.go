@@ -0,0 +1,61 @@
+package slackbot
+
+import (
+	"strconv"
+	"time"
+)
+
+// UnixTimestamp represents a time sent by Slack as a number of seconds since
+// the Unix epoch. Slack sends these both as integers and as floating point
+// numbers, and either may be decoded into a UnixTimestamp; any fractional
+// part is discarded.
+type UnixTimestamp int64
+
+// UnmarshalJSON decodes a JSON number, integer or not, into the timestamp.
+func (ts *UnixTimestamp) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if seconds, err := strconv.ParseInt(text, 10, 64); err == nil {
+		*ts = UnixTimestamp(seconds)
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return err
+	}
+	*ts = UnixTimestamp(seconds)
+	return nil
+}
+
+// Time returns the timestamp as a time.Time.
+func (ts UnixTimestamp) Time() time.Time {
+	return time.Unix(int64(ts), 0)
+}
+
+// tsLess reports whether the Slack message timestamp a, such as
+// "1503435956.000247", comes before b. Timestamps are compared by their
+// seconds and then by their fractional part, without parsing them as floats,
+// which would lose precision.
+func tsLess(a, b string) bool {
+	aSeconds, aFraction := splitTs(a)
+	bSeconds, bFraction := splitTs(b)
+	if len(aSeconds) != len(bSeconds) {
+		return len(aSeconds) < len(bSeconds)
+	}
+	if aSeconds != bSeconds {
+		return aSeconds < bSeconds
+	}
+	return aFraction < bFraction
+}
+
+// splitTs splits a message timestamp into its seconds and fractional part.
+func splitTs(ts string) (seconds, fraction string) {
+	for i := 0; i < len(ts); i++ {
+		if ts[i] == '.' {
+			return ts[:i], ts[i+1:]
+		}
+	}
+	return ts, ""
+}
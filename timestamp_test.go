@@ -0,0 +1,61 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnixTimestampUnmarshal(t *testing.T) {
+	for _, test := range []struct {
+		json string
+		want UnixTimestamp
+	}{
+		{`1503435956`, 1503435956},
+		{`1503435956.7`, 1503435956},
+		{`32503680000`, 32503680000}, // Beyond the range of 32-bit integers
+		{`3.2503680000e10`, 32503680000},
+		{`null`, 0},
+	} {
+		var ts UnixTimestamp
+		if err := json.Unmarshal([]byte(test.json), &ts); err != nil {
+			t.Errorf("unmarshalling %s: %v", test.json, err)
+		}
+		if ts != test.want {
+			t.Errorf("unmarshalled %s as %d, want %d", test.json, ts, test.want)
+		}
+	}
+	var ts UnixTimestamp
+	if err := json.Unmarshal([]byte(`"soon"`), &ts); err == nil {
+		t.Error("unmarshalled a string without error")
+	}
+}
+
+func TestDndUpdatedUserTimestamps(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var received DndUpdatedUser
+	bot.OnDndUpdatedUser = func(event DndUpdatedUser) error {
+		received = event
+		return nil
+	}
+	bot.handleEvent([]byte(`{"type":"dnd_updated_user","user":"U1",
+		"dnd_status":{"dnd_enabled":true,"next_dnd_start_ts":32503680000,"next_dnd_end_ts":1503435956.5}}`))
+	if received.DndStatus.NextDndStartTs != 32503680000 || received.DndStatus.NextDndEndTs != 1503435956 {
+		t.Errorf("timestamps unmarshalled as %+v", received.DndStatus)
+	}
+}
+
+func TestTsLess(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		{"1503435956.000247", "1503435956.000248", true},
+		{"1503435956.000248", "1503435956.000247", false},
+		{"999999999.999999", "1000000000.000000", true},
+		{"1.0", "1.0", false},
+	} {
+		if got := tsLess(test.a, test.b); got != test.want {
+			t.Errorf("tsLess(%s, %s) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package slackbot
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// typingInterval is how often SendWithTyping repeats the typing indicator,
+// which Slack otherwise clears after a few seconds.
+const typingInterval = 3 * time.Second
+
+// SendTyping shows other users of a given channel that the bot is typing,
+// until it sends a message or a few seconds have passed. Typing indicators
+// are sent in order with the messages to the channel, but are not held back
+// by SendRate, nor count towards it. They cannot be sent in Socket Mode or
+// dry-run mode, in which case SendTyping does nothing.
+// Slack API doc: https://api.slack.com/rtm#typing_indicators
+func (bot *SlackBot) SendTyping(channel string) error {
+	if bot.SocketMode || bot.DryRun {
+		return nil
+	}
+	ctx := context.Background()
+	if err := bot.waitReady(ctx); err != nil {
+		return err
+	}
+	request := &sendRequest{
+		ctx:     ctx,
+		channel: channel,
+		message: &messageOut{
+			ID:      atomic.AddInt32(&bot.messageID, 1),
+			Type:    "typing",
+			Channel: channel,
+		},
+		sent:   make(chan error, 1),
+		typing: true,
+	}
+	bot.enqueue(request)
+	return <-request.sent
+}
+
+// SendWithTyping shows that the bot is typing in a given channel for a given
+// delay, and then sends a given message to the channel.
+func (bot *SlackBot) SendWithTyping(channel, message string, delay time.Duration) error {
+	if err := bot.SendTyping(channel); err != nil {
+		return err
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return bot.SendMessage(channel, message)
+		case <-ticker.C:
+			if err := bot.SendTyping(channel); err != nil {
+				return err
+			}
+		}
+	}
+}
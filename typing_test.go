@@ -0,0 +1,62 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendWithTyping(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	delay := 50 * time.Millisecond
+	done := make(chan error, 1)
+	go func() { done <- bot.SendWithTyping("C1", "thought about it", delay) }()
+	typing := c.next(t)
+	typedAt := time.Now()
+	if typing["type"] != "typing" || typing["channel"] != "C1" {
+		t.Fatalf("sent %v first, want the typing indicator", typing)
+	}
+	message := c.next(t)
+	if elapsed := time.Since(typedAt); elapsed < delay*4/5 {
+		t.Errorf("message sent %v after the typing indicator, want at least %v", elapsed, delay)
+	}
+	if message["type"] != "message" || message["text"] != "thought about it" {
+		t.Errorf("sent %v, want the message", message)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSendTypingIgnoresSendRate(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.SendRate = 1
+	start := time.Now()
+	if err := bot.SendTyping("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMessage("C1", "right away"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("message sent %v after the typing indicator, want no wait", elapsed)
+	}
+	if typing := c.next(t); typing["type"] != "typing" {
+		t.Errorf("sent %v first, want the typing indicator", typing)
+	}
+	if message := c.next(t); message["text"] != "right away" {
+		t.Errorf("sent %v, want the message", message)
+	}
+}
+
+func TestSendTypingInDryRun(t *testing.T) {
+	bot, c := newConnectedBot(t)
+	bot.DryRun = true
+	if err := bot.SendTyping("C1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sent := <-c.sent:
+		t.Errorf("sent %s in dry-run mode", sent)
+	default:
+	}
+}
@@ -0,0 +1,58 @@
+package slackbot
+
+import "context"
+
+// eventWaiter is a call to WaitFor waiting for a matching event.
+type eventWaiter struct {
+	match   func(Event) bool
+	matched chan Event
+}
+
+// WaitFor blocks until the bot receives an event for which a given function
+// returns true, and returns that event, or returns the error of the context
+// if it is done first. Events are passed on to callbacks as usual, and the
+// function is called from the goroutine dispatching each event, so it
+// should be quick. If DispatchSync is set, WaitFor must not be called from
+// a callback, since no events are dispatched while the callback waits.
+func (bot *SlackBot) WaitFor(ctx context.Context, match func(Event) bool) (Event, error) {
+	waiter := &eventWaiter{match: match, matched: make(chan Event, 1)}
+	bot.waiterMutex.Lock()
+	if bot.waiters == nil {
+		bot.waiters = make(map[*eventWaiter]bool)
+	}
+	bot.waiters[waiter] = true
+	bot.waiterMutex.Unlock()
+	defer func() {
+		bot.waiterMutex.Lock()
+		delete(bot.waiters, waiter)
+		bot.waiterMutex.Unlock()
+	}()
+	select {
+	case event := <-waiter.matched:
+		return event, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// notifyWaiters passes a given event on to the calls to WaitFor waiting for it.
+func (bot *SlackBot) notifyWaiters(event Event) {
+	bot.waiterMutex.Lock()
+	waiters := make([]*eventWaiter, 0, len(bot.waiters))
+	for waiter := range bot.waiters {
+		waiters = append(waiters, waiter)
+	}
+	bot.waiterMutex.Unlock()
+	for _, waiter := range waiters {
+		if !waiter.match(event) {
+			continue
+		}
+		// Each waiter takes only the first matching event
+		bot.waiterMutex.Lock()
+		if bot.waiters[waiter] {
+			delete(bot.waiters, waiter)
+			waiter.matched <- event
+		}
+		bot.waiterMutex.Unlock()
+	}
+}
@@ -0,0 +1,66 @@
+package slackbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waiting returns the number of calls to WaitFor waiting for an event.
+func waiting(bot *SlackBot) int {
+	bot.waiterMutex.Lock()
+	defer bot.waiterMutex.Unlock()
+	return len(bot.waiters)
+}
+
+func TestWaitFor(t *testing.T) {
+	bot, _ := newTestBot(t)
+	var messages int
+	bot.OnMessage = func(event MessageIn) error {
+		messages++
+		return nil
+	}
+	type result struct {
+		event Event
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		event, err := bot.WaitFor(context.Background(), func(event Event) bool {
+			message, ok := event.(MessageIn)
+			return ok && message.Text == "deploy"
+		})
+		done <- result{event, err}
+	}()
+	eventually(t, func() bool { return waiting(bot) == 1 }, "WaitFor not waiting")
+	bot.Dispatch([]byte(`{"type":"presence_change","user":"U1","presence":"away"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"hello","ts":"1.0"}`))
+	bot.Dispatch([]byte(`{"type":"message","channel":"C1","user":"U1","text":"deploy","ts":"2.0"}`))
+	select {
+	case r := <-done:
+		if message, ok := r.event.(MessageIn); r.err != nil || !ok || message.Ts != "2.0" {
+			t.Errorf("got event %+v and error %v", r.event, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitFor did not return on a matching event")
+	}
+	if messages != 2 {
+		t.Errorf("OnMessage called %d times, want 2", messages)
+	}
+	if waiting(bot) != 0 {
+		t.Error("WaitFor still waiting after returning")
+	}
+}
+
+func TestWaitForContextDone(t *testing.T) {
+	bot, _ := newTestBot(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	event, err := bot.WaitFor(ctx, func(event Event) bool { return true })
+	if err != context.DeadlineExceeded || event != nil {
+		t.Errorf("got event %v and error %v, want deadline exceeded", event, err)
+	}
+	if waiting(bot) != 0 {
+		t.Error("WaitFor still waiting after returning")
+	}
+}
@@ -0,0 +1,162 @@
+package slackbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// defaultAPIURL is the default value of SlackBot.APIURL.
+const defaultAPIURL = "https://slack.com/api/"
+
+// APIError represents an error returned by the Slack Web API, such as
+// "not_in_channel" or "invalid_auth". The full list of possible codes
+// is documented for each method at https://api.slack.com/methods
+type APIError struct {
+	Code string
+}
+
+func (err APIError) Error() string {
+	return "Slack error: " + err.Code
+}
+
+// StatusError represents a request to the Slack Web API that failed with
+// an HTTP status other than 200 OK.
+type StatusError struct {
+	Method     string
+	StatusCode int
+}
+
+func (err StatusError) Error() string {
+	return fmt.Sprintf("API request %s failed with code %d", err.Method, err.StatusCode)
+}
+
+// isTemporary reports whether an error seen while calling the Web API or
+// connecting to Slack may go away if retried, which is the case for network
+// errors, server errors, and rate limiting. Errors reported by Slack itself,
+// such as "invalid_auth", are permanent.
+func isTemporary(err error) bool {
+	switch err := err.(type) {
+	case APIError:
+		return false
+	case StatusError:
+		return err.StatusCode >= 500 || err.StatusCode == http.StatusTooManyRequests
+	case net.Error, *websocket.DialError:
+		return true
+	}
+	return false
+}
+
+// apiResponse holds the fields common to all Slack Web API responses.
+type apiResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+func (resp apiResponse) err() error {
+	if !resp.Ok {
+		return APIError{Code: resp.Error}
+	}
+	return nil
+}
+
+// responseMetadata holds the pagination cursor included in responses
+// from paginated Web API methods.
+// Slack API doc: https://api.slack.com/docs/pagination
+type responseMetadata struct {
+	NextCursor string `json:"next_cursor"`
+}
+
+// httpClient returns the client used for all requests to the Web API,
+// which uses the bot's TLSConfig if one is given.
+func (bot *SlackBot) httpClient() *http.Client {
+	bot.httpClientOnce.Do(func() {
+		if bot.TLSConfig == nil {
+			bot.client = http.DefaultClient
+			return
+		}
+		// Start from the default transport to keep its timeouts, limits on
+		// idle connections, and HTTP/2 support
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = bot.TLSConfig
+		bot.client = &http.Client{Transport: transport}
+	})
+	return bot.client
+}
+
+// methodURL returns the URL of a given Web API method.
+func (bot *SlackBot) methodURL(method string) string {
+	if bot.APIURL == "" {
+		return defaultAPIURL + method
+	}
+	return bot.APIURL + method
+}
+
+// callAPI calls the given Slack Web API method with the given form
+// parameters, authenticating with the bot's token, and unmarshals the
+// JSON response into response. response should embed apiResponse so that
+// Slack errors can be detected.
+func (bot *SlackBot) callAPI(method string, params url.Values, response interface{ err() error }) (err error) {
+	req, err := http.NewRequest("POST", bot.methodURL(method), strings.NewReader(params.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return bot.doAPIRequest(method, req, response)
+}
+
+// callAPIJSON is like callAPI, but sends the parameters as a JSON body, which
+// is needed for methods taking structured arguments such as chat.postMessage.
+func (bot *SlackBot) callAPIJSON(method string, params interface{}, response interface{ err() error }) (err error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", bot.methodURL(method), bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return bot.doAPIRequest(method, req, response)
+}
+
+// doAPIRequest authenticates and performs a request to a Web API method
+// and unmarshals its response. Requests are authenticated with the bot's
+// token unless they already carry another one. Responses needing
+// information from the response headers may get it by implementing readHeader.
+func (bot *SlackBot) doAPIRequest(method string, req *http.Request, response interface{ err() error }) (err error) {
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+bot.token)
+	}
+	if bot.UserAgent != "" {
+		req.Header.Set("User-Agent", bot.UserAgent)
+	}
+	resp, err := bot.httpClient().Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		err = StatusError{Method: method, StatusCode: resp.StatusCode}
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(body, response); err != nil {
+		return
+	}
+	if headerReader, ok := response.(interface{ readHeader(http.Header) }); ok {
+		headerReader.readHeader(resp.Header)
+	}
+	return response.err()
+}